@@ -4,11 +4,22 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/voormedia/kd/pkg/build"
 	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/registry"
 )
 
 var buildTag string = ""
 var buildCacheTag string = ""
 var buildNoCacheWrite bool = false
+var buildBranchTag bool = false
+var buildLocal bool = false
+var buildOutputFile string = ""
+var buildOutputFormat string = "docker"
+var buildBuilder string = ""
+var buildReuse bool = false
+var buildProvenance bool = false
+var buildSBOM bool = false
+var buildStrict bool = false
+var buildMetadataFile string = ""
 var secrets []string
 
 var cmdBuild = &cobra.Command{
@@ -21,9 +32,52 @@ var cmdBuild = &cobra.Command{
 
 	Long: `Builds a single application. If only one application is configured, the name
 can be omitted. Application images will be pushed to the registry and tagged
-as "latest" by default. The tag can optionally be specified.`,
+as "latest" by default. The tag can optionally be specified.
 
-	Example: "  kd build my-app\n  kd build my-app:awesome-tag",
+Images built from a clean git working tree are also tagged with the full git
+commit hash, and labelled with the standard OCI annotations for the revision,
+source, creation time, version and title.
+
+With --local the image is loaded into the local Docker image store instead of
+being pushed, or exported to a tarball with --output-file. Remote cache is
+still read, but never written.
+
+Images are built with docker buildx by default. Podman, Buildah or the Kaniko
+executor can be used instead with the 'builder' option of an application in
+kdeploy.conf, or with --builder.
+
+With --reuse, a fingerprint of the build inputs is computed from the files in
+the build context that are tracked by git and not excluded by .dockerignore,
+the Dockerfile, the platform, the ids of secrets, the pre-build command, the
+requested attestations and the builder. If an image with the same fingerprint
+was built before, it is tagged instead of building it again. The pre-build
+command is not run, but the post-build command is, with the digest of the
+reused image. Files generated by the pre-build command are not part of the
+fingerprint; do not use --reuse if they depend on anything but the inputs
+above. A reused image keeps its labels, so its revision label refers to the
+commit it was originally built from.
+
+Build secrets are declared with the 'secrets' option of an application in
+kdeploy.conf, or given with --secret in the format of docker buildx, such as
+id=npm,env=NPM_TOKEN or id=npm,src=.npmrc. The build fails early if the source
+of a secret is missing.
+
+Before building, the files in the build context that are not excluded by
+.dockerignore are checked for likely secrets, such as private keys, .env files,
+cloud credentials and git repositories, and for oversized files. With --strict,
+or the 'strict' option of an application in kdeploy.conf, such files fail the
+build.
+
+With --provenance and --sbom, SLSA provenance and SPDX SBOM attestations are
+attached to the pushed image. They can also be enabled for an application with
+the 'provenance' and 'sbom' options in kdeploy.conf. Use 'kd inspect' to
+summarise them. Attestations are only supported by the buildx builder.
+
+With --metadata-file, the result of the build is written to a JSON file. It
+contains the image, its tags, digest and platforms, and the git revision. The
+digest can be used to deploy exactly this image with 'kd deploy app@digest'.`,
+
+	Example: "  kd build my-app\n  kd build my-app:awesome-tag\n  kd build my-app --local",
 
 	PreRun: func(cmd *cobra.Command, args []string) {
 		if conf, err := config.Load(); err == nil {
@@ -37,17 +91,51 @@ as "latest" by default. The tag can optionally be specified.`,
 			log.Fatal(err)
 		}
 
+		registry.Configure(conf)
+
 		name := ""
 		if len(args) > 0 {
 			name = args[0]
 		}
 
+		if buildOutputFile != "" {
+			buildLocal = true
+		}
+
+		if buildOutputFormat != "docker" && buildOutputFormat != "oci" {
+			log.Fatal(`Output format must be either "docker" or "oci"`)
+		}
+
+		var parsedSecrets []config.Secret
+		for _, value := range secrets {
+			secret, err := build.ParseSecret(value)
+			if err != nil {
+				log.Fatal(err)
+			}
+			parsedSecrets = append(parsedSecrets, secret)
+		}
+
 		app, err := conf.ResolveApp(name, buildTag)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = build.Run(log, app, !buildNoCacheWrite, buildCacheTag, secrets, "kd " + cmdRoot.Version)
+		err = build.Run(log, app, build.Options{
+			WriteBuildCache: !buildNoCacheWrite,
+			BuildCacheTag:   buildCacheTag,
+			Secrets:         parsedSecrets,
+			Producer:        "kd " + cmdRoot.Version,
+			BranchTag:       buildBranchTag,
+			Local:           buildLocal,
+			OutputFile:      buildOutputFile,
+			OutputFormat:    buildOutputFormat,
+			Builder:         buildBuilder,
+			Reuse:           buildReuse,
+			Provenance:      buildProvenance,
+			SBOM:            buildSBOM,
+			Strict:          buildStrict,
+			MetadataFile:    buildMetadataFile,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -58,6 +146,16 @@ func init() {
 	cmdBuild.Flags().StringVar(&buildTag, "tag", "", "tag to use for the built image")
 	cmdBuild.Flags().StringVar(&buildCacheTag, "cache-tag", "", "tag to use for build cache (defaults to git branch)")
 	cmdBuild.Flags().BoolVar(&buildNoCacheWrite, "no-cache-write", false, "do not write to remote cache after build (reduces network traffic)")
-	cmdBuild.Flags().StringArrayVar(&secrets, "secret", []string{}, "secrets to inject into the build environment")
+	cmdBuild.Flags().BoolVar(&buildBranchTag, "branch-tag", false, "also tag the image with <branch>-<short commit hash>")
+	cmdBuild.Flags().BoolVar(&buildLocal, "local", false, "load the image into the local image store instead of pushing it")
+	cmdBuild.Flags().StringVar(&buildOutputFile, "output-file", "", "export the image to a tarball instead of pushing it (implies --local)")
+	cmdBuild.Flags().StringVar(&buildOutputFormat, "output-format", "docker", `format of the exported tarball, either "docker" or "oci"`)
+	cmdBuild.Flags().StringVar(&buildBuilder, "builder", "", "tool to build with, either \"buildx\", \"podman\", \"buildah\" or \"kaniko\"")
+	cmdBuild.Flags().BoolVar(&buildReuse, "reuse", false, "tag an existing image built from the same source instead of building")
+	cmdBuild.Flags().BoolVar(&buildProvenance, "provenance", false, "attach SLSA provenance attestations to the image")
+	cmdBuild.Flags().BoolVar(&buildSBOM, "sbom", false, "attach SPDX SBOM attestations to the image")
+	cmdBuild.Flags().StringVar(&buildMetadataFile, "metadata-file", "", "write the result of the build to a JSON file")
+	cmdBuild.Flags().BoolVar(&buildStrict, "strict", false, "fail if the build context contains likely secrets or oversized files")
+	cmdBuild.Flags().StringArrayVar(&secrets, "secret", []string{}, "secret to expose to the build, e.g. id=npm,env=NPM_TOKEN or id=npm,src=.npmrc")
 	cmdRoot.AddCommand(cmdBuild)
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/buildx"
+	"github.com/voormedia/kd/pkg/config"
+)
+
+var builderStatusOutput formatType = formatTable
+
+var cmdBuilder = &cobra.Command{
+	Use:                   "builder",
+	Short:                 "Manage the buildx builder for this project",
+	DisableFlagsInUseLine: true,
+
+	Long: `Manages the buildx builder that is configured in the 'buildxBuilder' section
+of kdeploy.conf. The builder is also created automatically by 'kd build' if it
+does not exist yet.`,
+}
+
+var cmdBuilderStatus = &cobra.Command{
+	Use:                   "status",
+	Short:                 "Show the state of the buildx builder",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.NoArgs,
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		status, err := buildx.Inspect(log, conf.BuildxBuilder)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if builderStatusOutput == formatJSON {
+			printJSON(status)
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+		fmt.Fprintf(tw, "NAME\t%s\n", status.Name)
+		fmt.Fprintf(tw, "DRIVER\t%s\n", status.Driver)
+		fmt.Fprintf(tw, "STATUS\t%s\n", status.Status)
+		fmt.Fprintf(tw, "PLATFORMS\t%s\n", valueOrNone(strings.Join(status.Platforms, ", ")))
+		fmt.Fprintf(tw, "CACHE EXPORT\t%t\n", status.CacheExport)
+		tw.Flush()
+
+		for _, problem := range status.Problems {
+			log.Warn(problem)
+		}
+	},
+}
+
+var cmdBuilderCreate = &cobra.Command{
+	Use:                   "create",
+	Short:                 "Create the buildx builder",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.NoArgs,
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := buildx.Create(log, conf.BuildxBuilder); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Success("Successfully created builder", conf.BuildxBuilder.Name)
+	},
+}
+
+var cmdBuilderRm = &cobra.Command{
+	Use:                   "rm",
+	Short:                 "Remove the buildx builder",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.NoArgs,
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := buildx.Remove(log, conf.BuildxBuilder); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Success("Successfully removed builder", conf.BuildxBuilder.Name)
+	},
+}
+
+func init() {
+	cmdBuilderStatus.Flags().VarP(&builderStatusOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdBuilder.AddCommand(cmdBuilderStatus)
+	cmdBuilder.AddCommand(cmdBuilderCreate)
+	cmdBuilder.AddCommand(cmdBuilderRm)
+	cmdRoot.AddCommand(cmdBuilder)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/cache"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/registry"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+var cachePruneMaxAge string = ""
+var cachePruneDryRun bool = false
+var cachePruneOutput formatType = formatTable
+
+var cmdCache = &cobra.Command{
+	Use:                   "cache",
+	Short:                 "Manage build cache images in the registry",
+	DisableFlagsInUseLine: true,
+}
+
+var cmdCachePrune = &cobra.Command{
+	Use:                   "prune [app]",
+	Short:                 "Delete stale build cache images from the registry",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(0, 1),
+
+	Long: `Deletes build cache images of a single application from the registry. If only
+one application is configured, the name can be omitted.
+
+Cache images are stale if the git branch they were built from no longer exists
+locally or on any remote, or if they are older than --max-age. Cache images of
+the default branches are always kept. Fetch remote branches before pruning to
+avoid deleting cache images of branches you do not have locally.`,
+
+	Example: "  kd cache prune my-app --dry-run\n  kd cache prune my-app --max-age 30d",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.AppNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		policy := cache.Policy{}
+		if cachePruneMaxAge != "" {
+			policy.MaxAge, err = util.ParseAge(cachePruneMaxAge)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		plan, err := cache.Plan(log, app, policy)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if cachePruneOutput == formatTable {
+			printCachePlan(plan)
+		}
+
+		if !cachePruneDryRun && len(plan) > 0 {
+			err = cache.Prune(log, app, plan)
+		}
+
+		if cachePruneOutput == formatJSON {
+			printJSON(plan)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(plan) == 0 {
+			log.Success("No stale build cache images found for", app.Name)
+		} else if cachePruneDryRun {
+			log.Success("Found", len(plan), "stale build cache images for", app.Name)
+		} else {
+			log.Success("Deleted", len(plan), "stale build cache images for", app.Name)
+		}
+	},
+}
+
+func printCachePlan(plan []cache.Entry) {
+	if len(plan) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "TAG\tDIGEST\tAGE\tREASON\n")
+	for _, entry := range plan {
+		age := "unknown"
+		if entry.Created != nil {
+			age = util.FormatAge(time.Since(*entry.Created))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Name, shortDigest(entry.Digest), age, entry.Reason)
+	}
+	tw.Flush()
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 19 {
+		return digest[:19]
+	}
+	return digest
+}
+
+func init() {
+	cmdCachePrune.Flags().StringVar(&cachePruneMaxAge, "max-age", "", "also delete cache images older than this age (e.g. 30d)")
+	cmdCachePrune.Flags().BoolVar(&cachePruneDryRun, "dry-run", false, "only show which cache images would be deleted")
+	cmdCachePrune.Flags().VarP(&cachePruneOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdCache.AddCommand(cmdCachePrune)
+	cmdRoot.AddCommand(cmdCache)
+}
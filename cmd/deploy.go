@@ -4,13 +4,15 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/voormedia/kd/pkg/config"
 	"github.com/voormedia/kd/pkg/deploy"
+	"github.com/voormedia/kd/pkg/registry"
 )
 
 var deployTag string = ""
+var deployDigest string = ""
 var deployClearCDNCaches bool = false
 
 var cmdDeploy = &cobra.Command{
-	Use:                   "deploy [app[:tag]] <target>",
+	Use:                   "deploy [app[:tag|@digest]] <target>",
 	Short:                 "Configure and deploy an application to a cluster",
 	DisableFlagsInUseLine: true,
 
@@ -22,10 +24,14 @@ is configured, the name can be omitted. By default the application image with
 the 'latest' tag in the registry will be deployed. The tag of the image to
 deploy can optionally be specified.
 
+To deploy exactly the image that was built, specify its digest with
+'app@sha256:...' or with --digest. The digest is written to the metadata file
+of 'kd build --metadata-file'.
+
 Any image that was successfully deployed will be tagged with the name of the
 target to which it was deployed.`,
 
-	Example: "  kd deploy my-app production",
+	Example: "  kd deploy my-app production\n  kd deploy my-app@sha256:4f2b... production",
 
 	PreRun: func(cmd *cobra.Command, args []string) {
 		if conf, err := config.Load(); err == nil {
@@ -39,11 +45,17 @@ target to which it was deployed.`,
 			log.Fatal(err)
 		}
 
+		registry.Configure(conf)
+
 		name := ""
 		if len(args) > 1 {
 			name = args[0]
 		}
 
+		if deployDigest != "" {
+			name += "@" + deployDigest
+		}
+
 		tgt, err := conf.ResolveTarget(args[len(args)-1])
 		if err != nil {
 			log.Fatal(err)
@@ -63,6 +75,7 @@ target to which it was deployed.`,
 
 func init() {
 	cmdDeploy.Flags().StringVar(&deployTag, "tag", "", "tag to deploy")
+	cmdDeploy.Flags().StringVar(&deployDigest, "digest", "", "digest of the image to deploy")
 	cmdDeploy.Flags().BoolVar(&deployClearCDNCaches, "clear-cdn-cache", false, "clear any CDN cache after deployment")
 	cmdRoot.AddCommand(cmdDeploy)
 }
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	kdexec "github.com/voormedia/kd/pkg/exec"
+	"github.com/voormedia/kd/pkg/registry"
+)
+
+var execOptions kdexec.Options
+
+var cmdExec = &cobra.Command{
+	Use:                   "exec [app] <target> -- <command> [args ...]",
+	Short:                 "Execute a command in a running pod of an application",
+	DisableFlagsInUseLine: true,
+
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 1 || dash > 2 || len(args) == dash {
+			return errors.New("requires [app] <target> followed by -- and a command")
+		}
+		return nil
+	},
+
+	Long: `Executes a command in a container of a ready pod of a single application in
+the given target. If only one application is configured, the name can be
+omitted.
+
+By default the most recently started ready pod is used, and the container that
+runs the image of the application. Use --choose to select one of the ready pods
+instead. A TTY is allocated when running in a terminal. The exit code of the
+command is passed through.`,
+
+	Example: "  kd exec my-app production -- bin/rails console\n  kd exec my-app production --choose -- env",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.TargetNames()
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		dash := cmd.ArgsLenAtDash()
+		app, tgt := resolveExecArgs(args[:dash])
+		exitWithStatus(kdexec.Run(log, app, tgt, execOptions, args[dash:]))
+	},
+}
+
+var cmdShell = &cobra.Command{
+	Use:                   "shell [app] <target>",
+	Short:                 "Start a shell in a running pod of an application",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(1, 2),
+
+	Long: `Starts an interactive shell in a container of a ready pod of a single
+application in the given target. If only one application is configured, the
+name can be omitted. Bash is used if it is available, otherwise sh.
+
+The pod and container are selected as with 'kd exec'.`,
+
+	Example: "  kd shell my-app production\n  kd shell my-app production --choose",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.TargetNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		app, tgt := resolveExecArgs(args)
+		exitWithStatus(kdexec.Shell(log, app, tgt, execOptions))
+	},
+}
+
+func resolveExecArgs(args []string) (*config.ResolvedApp, *config.ResolvedTarget) {
+	conf, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	registry.Configure(conf)
+
+	name := ""
+	if len(args) > 1 {
+		name = args[0]
+	}
+
+	tgt, err := conf.ResolveTarget(args[len(args)-1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app, err := conf.ResolveApp(name, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return app, tgt
+}
+
+// Exits with the exit code of a failed command, or reports any other error.
+// Local commands that fail already reported their failure.
+func exitWithStatus(err error) {
+	if err == nil {
+		return
+	}
+
+	var cmdErr *exec.ExitError
+	if errors.As(err, &cmdErr) && cmdErr.ExitCode() > 0 {
+		os.Exit(cmdErr.ExitCode())
+	}
+
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		log.Error(err)
+		os.Exit(exitErr.ExitCode())
+	}
+
+	log.Fatal(err)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{cmdExec, cmdShell} {
+		cmd.Flags().StringVar(&execOptions.Pod, "pod", "", "name of the pod to use")
+		cmd.Flags().BoolVar(&execOptions.Choose, "choose", false, "choose one of the ready pods interactively")
+		cmd.Flags().StringVarP(&execOptions.Container, "container", "c", "", "name of the container to use")
+		cmdRoot.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type formatType string
+
+const (
+	formatTable formatType = "table"
+	formatJSON  formatType = "json"
+)
+
+func (e *formatType) String() string {
+	return `"` + string(*e) + `"`
+}
+
+func (e *formatType) Set(v string) error {
+	switch v {
+	case "table", "json":
+		*e = formatType(v)
+		return nil
+	default:
+		return errors.New(`must be either "table" or "json"`)
+	}
+}
+
+func (e *formatType) Type() string {
+	return "type"
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/images"
+	"github.com/voormedia/kd/pkg/registry"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+var imagesOutput formatType = formatTable
+var imagesPruneKeep int = 0
+var imagesPruneMaxAge string = ""
+var imagesPruneDryRun bool = false
+var imagesPruneYes bool = false
+var imagesPruneOutput formatType = formatTable
+
+var cmdImages = &cobra.Command{
+	Use:                   "images [app]",
+	Short:                 "List the images of an application in the registry",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(0, 1),
+
+	Long: `Lists the images of a single application in the registry, with their tags,
+digest, age, size, platforms and source revision. If only one application is
+configured, the name can be omitted.
+
+Images that are deployed to a target are tagged with the name of the target.
+They are listed first, with the targets they are deployed to.`,
+
+	Example: "  kd images my-app\n  kd images my-app -o json",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.AppNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		list, err := images.List(log, app, conf.TargetNames())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if imagesOutput == formatJSON {
+			printJSON(list)
+			return
+		}
+
+		printImages(list)
+	},
+}
+
+var cmdImagesPrune = &cobra.Command{
+	Use:                   "prune [app]",
+	Short:                 "Delete old images of an application from the registry",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(0, 1),
+
+	Long: `Deletes old images of a single application from the registry according to the
+retention policy in kdeploy.conf, or the policy given with --keep and --max-age.
+If only one application is configured, the name can be omitted.
+
+Images are kept if they are among the most recent ones or younger than the
+maximum age. Images tagged "latest", deployed to a target, or used by pods of
+any target are always kept. The plan is shown and has to be confirmed before
+any image is deleted, unless --yes is given.`,
+
+	Example: "  kd images prune my-app --dry-run\n  kd images prune my-app --keep 10 --max-age 30d",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.AppNames()
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		policy, err := images.ConfiguredPolicy(app)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if cmd.Flags().Changed("keep") {
+			if imagesPruneKeep < 0 {
+				log.Fatal("Option --keep must not be negative")
+			}
+			policy.Keep = imagesPruneKeep
+		}
+
+		if imagesPruneMaxAge != "" {
+			policy.MaxAge, err = util.ParseAge(imagesPruneMaxAge)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		var targets []*config.ResolvedTarget
+		for _, name := range conf.TargetNames() {
+			target, err := conf.ResolveTarget(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			targets = append(targets, target)
+		}
+
+		plan, err := images.Plan(log, app, targets, policy)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		deletions := 0
+		for _, entry := range plan {
+			if !entry.Keep {
+				deletions++
+			}
+		}
+
+		confirm := !imagesPruneDryRun && deletions > 0 && !imagesPruneYes
+		if imagesPruneOutput == formatTable {
+			printImagesPlan(os.Stdout, plan)
+		} else if confirm {
+			// Show the plan before asking, without mixing it into the JSON.
+			printImagesPlan(os.Stderr, plan)
+		}
+
+		if confirm {
+			confirmed := false
+			prompt := &survey.Confirm{Message: fmt.Sprintf("Delete %d images of %s?", deletions, app.Name)}
+			if err := survey.AskOne(prompt, &confirmed, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)); err != nil {
+				log.Fatal(err)
+			}
+
+			if !confirmed {
+				if imagesPruneOutput == formatJSON {
+					printJSON(plan)
+				}
+				log.Note("No images deleted")
+				return
+			}
+		}
+
+		if !imagesPruneDryRun && deletions > 0 {
+			err = images.Prune(log, app, plan)
+		}
+
+		if imagesPruneOutput == formatJSON {
+			printJSON(plan)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if deletions == 0 {
+			log.Success("No images to delete for", app.Name)
+		} else if imagesPruneDryRun {
+			log.Success("Found", deletions, "images to delete for", app.Name)
+		} else {
+			log.Success("Deleted", deletions, "images of", app.Name)
+		}
+	},
+}
+
+func printImagesPlan(out io.Writer, plan []images.Entry) {
+	tw := tabwriter.NewWriter(out, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "ACTION\tTAGS\tDIGEST\tAGE\tSIZE\tREASON\n")
+	for _, entry := range plan {
+		action := "keep"
+		if !entry.Keep {
+			action = "DELETE"
+		}
+
+		age := "unknown"
+		if entry.Created != nil {
+			age = util.FormatAge(time.Since(*entry.Created))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			action,
+			strings.Join(entry.Tags, ", "),
+			shortDigest(entry.Digest),
+			age,
+			util.FormatSize(entry.Size),
+			entry.Reason,
+		)
+	}
+	tw.Flush()
+}
+
+func printImages(list []images.Image) {
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "TARGETS\tTAGS\tDIGEST\tAGE\tSIZE\tPLATFORMS\tREVISION\n")
+	for _, image := range list {
+		var tags []string
+		for _, tag := range image.Tags {
+			if !slices.Contains(image.Targets, tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		age := "unknown"
+		if image.Created != nil {
+			age = util.FormatAge(time.Since(*image.Created))
+		}
+
+		revision := image.Revision
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			strings.ToUpper(strings.Join(image.Targets, ", ")),
+			valueOrNone(strings.Join(tags, ", ")),
+			shortDigest(image.Digest),
+			age,
+			util.FormatSize(image.Size),
+			strings.Join(image.Platforms, ", "),
+			valueOrNone(revision),
+		)
+	}
+	tw.Flush()
+}
+
+func init() {
+	cmdImages.Flags().VarP(&imagesOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdImagesPrune.Flags().IntVar(&imagesPruneKeep, "keep", 0, "number of most recent images to keep")
+	cmdImagesPrune.Flags().StringVar(&imagesPruneMaxAge, "max-age", "", "keep images younger than this age (e.g. 30d)")
+	cmdImagesPrune.Flags().BoolVar(&imagesPruneDryRun, "dry-run", false, "only show which images would be deleted")
+	cmdImagesPrune.Flags().BoolVarP(&imagesPruneYes, "yes", "y", false, "delete images without asking for confirmation")
+	cmdImagesPrune.Flags().VarP(&imagesPruneOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdImages.AddCommand(cmdImagesPrune)
+	cmdRoot.AddCommand(cmdImages)
+}
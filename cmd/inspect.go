@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/inspect"
+	"github.com/voormedia/kd/pkg/registry"
+)
+
+var inspectOutput formatType = formatTable
+
+var cmdInspect = &cobra.Command{
+	Use:                   "inspect [app[:tag]]",
+	Short:                 "Show the attestations of an application image",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(0, 1),
+
+	Long: `Reads the provenance and SBOM attestations of an application image from the
+registry and summarises them for each platform: the source revision, the base
+images and the number of packages. If only one application is configured, the
+name can be omitted. The image tagged as "latest" is inspected by default.
+
+Attestations are attached to images built with --provenance or --sbom.`,
+
+	Example: "  kd inspect my-app\n  kd inspect my-app:awesome-tag -o json",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.AppNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		img, summaries, err := inspect.Run(log, app)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if inspectOutput == formatJSON {
+			printJSON(summaries)
+			return
+		}
+
+		log.Note("Image digest", img.Descriptor.Digest)
+		printInspectSummaries(summaries)
+	},
+}
+
+func printInspectSummaries(summaries []inspect.Summary) {
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	for i, summary := range summaries {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintf(tw, "PLATFORM\t%s\n", summary.Platform)
+		fmt.Fprintf(tw, "DIGEST\t%s\n", summary.Digest)
+		fmt.Fprintf(tw, "REVISION\t%s\n", valueOrNone(summary.Revision))
+		fmt.Fprintf(tw, "SOURCE\t%s\n", valueOrNone(summary.Source))
+
+		if summary.Provenance {
+			fmt.Fprintf(tw, "BASE IMAGES\t%s\n", valueOrNone(strings.Join(summary.BaseImages, "\n\t")))
+		} else {
+			fmt.Fprintf(tw, "PROVENANCE\t%s\n", "none")
+		}
+
+		if summary.SBOM {
+			fmt.Fprintf(tw, "PACKAGES\t%d\n", summary.Packages)
+		} else {
+			fmt.Fprintf(tw, "SBOM\t%s\n", "none")
+		}
+	}
+	tw.Flush()
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+func init() {
+	cmdInspect.Flags().VarP(&inspectOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdRoot.AddCommand(cmdInspect)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/logs"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+var logsSince string = ""
+var logsContainer string = ""
+var logsGrep string = ""
+var logsNoFollow bool = false
+
+var cmdLogs = &cobra.Command{
+	Use:                   "logs [app] <target>",
+	Short:                 "Stream the logs of all pods of an application",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(1, 2),
+
+	Long: `Streams the logs of all pods of a single application in the given target. If
+only one application is configured, the name can be omitted.
+
+The pods are found with the selectors of the deployments, stateful sets, daemon
+sets and jobs in the configuration of the application. Every line is prefixed
+with the name of its pod and container. Pods that start while following the
+logs, for example during a rollout, are picked up automatically.
+
+Log lines in the JSON format of Google Cloud Logging are shown with their
+severity and message, followed by any other fields.`,
+
+	Example: "  kd logs my-app production\n  kd logs my-app production --since 1h --grep 'status=5..'",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.TargetNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		name := ""
+		if len(args) > 1 {
+			name = args[0]
+		}
+
+		tgt, err := conf.ResolveTarget(args[len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		options := logs.Options{
+			Container: logsContainer,
+			Follow:    !logsNoFollow,
+		}
+
+		if logsSince != "" {
+			options.Since, err = util.ParseAge(logsSince)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if logsGrep != "" {
+			options.Grep, err = regexp.Compile(logsGrep)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		err = logs.Run(ctx, log, app, tgt, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	cmdLogs.Flags().StringVar(&logsSince, "since", "", "only show logs newer than this age, e.g. 10m, 2h or 1d")
+	cmdLogs.Flags().StringVarP(&logsContainer, "container", "c", "", "only show logs of containers with this name")
+	cmdLogs.Flags().StringVar(&logsGrep, "grep", "", "only show log lines that match this regular expression")
+	cmdLogs.Flags().BoolVar(&logsNoFollow, "no-follow", false, "show current logs and exit")
+	cmdRoot.AddCommand(cmdLogs)
+}
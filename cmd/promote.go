@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/promote"
+	"github.com/voormedia/kd/pkg/registry"
+)
+
+var promoteClearCDNCaches bool = false
+
+var cmdPromote = &cobra.Command{
+	Use:                   "promote [app] <from-target> <to-target>",
+	Short:                 "Deploy the image of one target to another target",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(2, 3),
+
+	Long: `Deploys the image that is currently deployed to one target to another
+target. If only one application is configured, the name can be omitted.
+
+The image is looked up by the tag with the name of the source target, which is
+added to every image that is deployed, and deployed by digest. Afterwards the
+digest and revision of the image before and after promotion are shown.`,
+
+	Example: "  kd promote my-app acceptance production",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.TargetNames()
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		name := ""
+		if len(args) > 2 {
+			name = args[0]
+		}
+
+		from, err := conf.ResolveTarget(args[len(args)-2])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		to, err := conf.ResolveTarget(args[len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		app, err := conf.ResolveApp(name, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		result, err := promote.Run(log, app, from, to, promoteClearCDNCaches)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printPromotion(result)
+	},
+}
+
+func printPromotion(result promote.Result) {
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "\tDIGEST\tREVISION\n")
+	fmt.Fprintf(tw, "BEFORE\t%s\t%s\n", valueOrNone(result.Before.Digest), valueOrNone(result.Before.Revision))
+	fmt.Fprintf(tw, "AFTER\t%s\t%s\n", valueOrNone(result.After.Digest), valueOrNone(result.After.Revision))
+	tw.Flush()
+
+	if result.Before.Digest == result.After.Digest {
+		log.Note("The promoted image was already deployed")
+	}
+}
+
+func init() {
+	cmdPromote.Flags().BoolVar(&promoteClearCDNCaches, "clear-cdn-cache", false, "clear any CDN cache after deployment")
+	cmdRoot.AddCommand(cmdPromote)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/run"
+)
+
+var runOptions run.Options
+
+var cmdRun = &cobra.Command{
+	Use:                   "run [app] <target> -- <command> [args ...]",
+	Short:                 "Run a command in a one-off job of an application",
+	DisableFlagsInUseLine: true,
+
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 1 || dash > 2 || len(args) == dash {
+			return errors.New("requires [app] <target> followed by -- and a command")
+		}
+		return nil
+	},
+
+	Long: `Runs a command in a one-off Kubernetes job for a single application in the
+given target. If only one application is configured, the name can be omitted.
+
+The job is derived from the deployment of the application. It runs the image
+that is currently deployed to the target, with the same environment, volumes
+and service account, but without other containers, probes, or the labels that
+would make it receive traffic. The logs of the job are streamed, and the exit
+code of the command is passed through. If the job cannot be scheduled, the
+reason is shown, and the command fails if the job has not started within 10
+minutes.
+
+The job is deleted when it has finished, unless --keep is given.`,
+
+	Example: "  kd run my-app production -- bin/rails db:migrate\n  kd run my-app production --keep -- bin/rake data:fix",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = conf.TargetNames()
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		dash := cmd.ArgsLenAtDash()
+		app, tgt := resolveExecArgs(args[:dash])
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		exitWithStatus(run.Run(ctx, log, app, tgt, runOptions, args[dash:]))
+	},
+}
+
+func init() {
+	cmdRun.Flags().BoolVar(&runOptions.Keep, "keep", false, "keep the job after it has finished")
+	cmdRoot.AddCommand(cmdRun)
+}
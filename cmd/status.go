@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/registry"
+	"github.com/voormedia/kd/pkg/status"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+var statusOutput formatType = formatTable
+
+var cmdStatus = &cobra.Command{
+	Use:                   "status [app] [target]",
+	Short:                 "Show what is running in each target",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.RangeArgs(0, 2),
+
+	Long: `Shows the status of applications in targets: the tag and digest of the image
+that is running, whether it is the image that was last deployed to the target,
+the state of the rollout, ready and desired replicas, restarts and the age of
+the oldest pod.
+
+Without arguments all applications in all targets are shown, as a matrix if
+there are multiple of both. A single application, a single target, or both can
+be given to only show their status.
+
+The running image is compared with the image that is tagged with the name of
+the target in the registry. An image that differs was not deployed with kd, or
+was changed since.`,
+
+	Example: "  kd status\n  kd status my-app\n  kd status production -o json",
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf, err := config.Load(); err == nil {
+			cmd.ValidArgs = append(conf.AppNames(), conf.TargetNames()...)
+		}
+	},
+
+	Run: func(_ *cobra.Command, args []string) {
+		conf, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		registry.Configure(conf)
+
+		appNames := conf.AppNames()
+		targetNames := conf.TargetNames()
+
+		switch {
+		case len(args) == 2:
+			appNames = []string{args[0]}
+			targetNames = []string{args[1]}
+		case len(args) == 1 && slices.Contains(appNames, args[0]):
+			appNames = []string{args[0]}
+		case len(args) == 1:
+			targetNames = []string{args[0]}
+		}
+
+		var apps []*config.ResolvedApp
+		for _, name := range appNames {
+			app, err := conf.ResolveApp(name, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+			apps = append(apps, app)
+		}
+
+		var targets []*config.ResolvedTarget
+		for _, name := range targetNames {
+			target, err := conf.ResolveTarget(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			targets = append(targets, target)
+		}
+
+		list := status.Get(log, apps, targets)
+
+		if statusOutput == formatJSON {
+			printJSON(list)
+			return
+		}
+
+		if len(apps) > 1 && len(targets) > 1 {
+			printStatusMatrix(list, appNames, targetNames)
+		} else {
+			printStatus(list)
+		}
+
+		for _, s := range list {
+			if s.Error != "" {
+				log.Warn("Could not retrieve status of", s.App, "in", s.Target+":", s.Error)
+			}
+		}
+	},
+}
+
+func printStatus(list []status.Status) {
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "APP\tTARGET\tROLLOUT\tREADY\tRESTARTS\tAGE\tTAG\tDIGEST\tCURRENT\n")
+	for _, s := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\t%s\t%s\n",
+			s.App,
+			s.Target,
+			s.Rollout,
+			s.Ready,
+			s.Desired,
+			s.Restarts,
+			statusAge(s),
+			valueOrNone(s.Tag),
+			valueOrNone(shortDigest(s.Digest)),
+			statusCurrent(s),
+		)
+	}
+	tw.Flush()
+}
+
+// Prints a matrix of apps and targets, with the tag or digest, the replicas
+// and the rollout state if it is not complete.
+func printStatusMatrix(list []status.Status, apps []string, targets []string) {
+	cells := map[string]string{}
+	differs := false
+	for _, s := range list {
+		image := s.Tag
+		if image == "" {
+			image = shortDigest(s.Digest)
+		}
+
+		var parts []string
+		if image != "" {
+			if s.Current != nil && !*s.Current {
+				image += "*"
+				differs = true
+			}
+			parts = append(parts, image)
+		}
+
+		if s.Rollout != status.RolloutMissing && s.Rollout != status.RolloutUnknown {
+			parts = append(parts, strconv.Itoa(int(s.Ready))+"/"+strconv.Itoa(int(s.Desired)))
+		}
+
+		if s.Rollout != status.RolloutComplete {
+			parts = append(parts, s.Rollout)
+		}
+
+		cells[s.App+"/"+s.Target] = strings.Join(parts, " ")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "APP\t%s\n", strings.ToUpper(strings.Join(targets, "\t")))
+	for _, app := range apps {
+		row := []string{app}
+		for _, target := range targets {
+			row = append(row, cells[app+"/"+target])
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+
+	if differs {
+		fmt.Println("\n* the running image is not the image tagged with the name of the target")
+	}
+}
+
+func statusAge(s status.Status) string {
+	if s.Started == nil {
+		return valueOrNone("")
+	}
+	return util.FormatAge(time.Since(*s.Started))
+}
+
+func statusCurrent(s status.Status) string {
+	switch {
+	case s.Digest == "":
+		return valueOrNone("")
+	case s.Current == nil:
+		return "unknown"
+	case *s.Current:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+func init() {
+	cmdStatus.Flags().VarP(&statusOutput, "output", "o", `output format, either "table" or "json"`)
+	cmdRoot.AddCommand(cmdStatus)
+}
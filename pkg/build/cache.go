@@ -0,0 +1,115 @@
+package build
+
+import (
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Configures SSH forwarding and remote build cache for the given build. Unless
+// a cache tag is given explicitly, cache tags are derived from the branches in
+// the cache fallback chain of the app, SSH forwarding and the ids of any
+// build secrets. Cache is always written to the tag of the current branch.
+func configureCache(log *util.Logger, b builder.Builder, app *config.ResolvedApp, spec *builder.Spec, opts Options) error {
+	buildCacheTag := opts.BuildCacheTag
+	buildCacheTagParts := []string{}
+
+	if sock, ok := os.LookupEnv("SSH_AUTH_SOCK"); ok {
+		conn, err := net.Dial("unix", sock)
+
+		if err != nil {
+			return err
+		}
+
+		signers, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			return err
+		}
+
+		if len(signers) == 0 {
+			log.Warn("Enabled SSH agent key forwarding, but no SSH keys are exposed")
+		} else {
+			log.Note("Enabled SSH agent key forwarding")
+			if buildCacheTag == "" {
+				buildCacheTagParts = append(buildCacheTagParts, "ssh")
+			}
+		}
+
+		spec.SSH = true
+	}
+
+	if buildCacheTag == "" {
+		for _, secret := range spec.Secrets {
+			buildCacheTagParts = append(buildCacheTagParts, util.Slugify(strings.ReplaceAll(secret.ID, "_", "-")))
+		}
+	}
+
+	var cacheFrom []string
+
+	if buildCacheTag == "" {
+		for _, branch := range cacheBranches(log, app) {
+			tag := strings.Join(append([]string{util.Slugify(branch)}, buildCacheTagParts...), "-")
+			ref := app.RepositoryBuildCache(tag)
+			if !slices.Contains(cacheFrom, ref) {
+				cacheFrom = append(cacheFrom, ref)
+			}
+		}
+	} else {
+		cacheFrom = []string{app.RepositoryBuildCache(buildCacheTag)}
+	}
+
+	spec.CacheMode = app.CacheMode
+
+	if opts.Local {
+		log.Note("Building locally, remote cache will not be written")
+		spec.CacheFrom = cacheFrom
+	} else if opts.WriteBuildCache {
+		if b.SupportsCacheExport(log) {
+			spec.CacheTo = cacheFrom[0]
+			spec.CacheFrom = cacheFrom
+		} else {
+			log.Warn("Builder does not support remote cache, using local cache only")
+		}
+	} else {
+		log.Warn("Skipping write to remote cache, using local cache only")
+	}
+
+	return nil
+}
+
+// Returns the branches to import build cache from, in order of preference.
+// The first branch is always the current branch.
+func cacheBranches(log *util.Logger, app *config.ResolvedApp) []string {
+	currentBranch, err := util.GetCurrentBranch(log, app.Path)
+	if err != nil {
+		log.Warn("Could not determine current branch:", err)
+		currentBranch = "unknown"
+	}
+
+	branches := []string{currentBranch}
+	for _, source := range app.CacheFrom {
+		switch source {
+		case config.CacheFromBranch:
+			// Always included first.
+		case config.CacheFromDefault:
+			branches = append(branches, app.DefaultBranch...)
+		case config.CacheFromMergeBase:
+			branch, err := util.GetForkBranch(log, app.Path, currentBranch, app.DefaultBranch)
+			if err != nil {
+				log.Debug("Could not determine branch to use cache from:", err)
+			} else if branch != "" {
+				branches = append(branches, branch)
+			}
+		default:
+			branches = append(branches, source)
+		}
+	}
+
+	return branches
+}
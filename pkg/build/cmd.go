@@ -1,45 +1,321 @@
 package build
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
 	"github.com/voormedia/kd/pkg/internal/docker"
 	"github.com/voormedia/kd/pkg/util"
 )
 
-func Run(log *util.Logger, app *config.ResolvedApp, writeBuildCache bool, buildCacheTag string, secrets []string, producer string) error {
+type Options struct {
+	WriteBuildCache bool
+	BuildCacheTag   string
+	Secrets         []config.Secret
+	Producer        string
+	BranchTag       bool
+	Local           bool
+	OutputFile      string
+	OutputFormat    string
+	Builder         string
+	Reuse           bool
+	Provenance      bool
+	SBOM            bool
+	Strict          bool
+	MetadataFile    string
+}
+
+func Run(log *util.Logger, app *config.ResolvedApp, opts Options) error {
 	if app.SkipBuild {
 		log.Fatal("Build is skipped for", app.Name)
 	}
 
-	if app.PreBuild != "" {
-		if strings.Contains(app.PreBuild, "/.ssh") {
-			log.Warn("Pre-build command in 'kdeploy.conf' contains reference to '.ssh'.")
-			log.Warn("Please use SSH key forwarding: https://github.com/voormedia/kd#ssh-forwarding")
+	if app.Digest != "" {
+		return fmt.Errorf("Images cannot be built by digest, specify a tag instead")
+	}
+
+	name := opts.Builder
+	if name == "" {
+		name = app.Builder
+	}
+
+	b, err := builder.New(name, app.BuildxBuilder.Name)
+	if err != nil {
+		return err
+	}
+
+	if b.Name() == builder.Default && !app.BuildxBuilder.IsEmpty() {
+		if err := builder.EnsureInstance(log, app.BuildxBuilder); err != nil {
+			return err
 		}
+	}
+
+	key, err := signingKey(log, app)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := resolveSecrets(log, app, opts.Secrets)
+	if err != nil {
+		return err
+	}
+
+	log.Note("Building", app.Name, "with", b.Name())
+
+	tags, labels, err := imageMetadata(log, app, opts)
+	if err != nil {
+		return err
+	}
+
+	spec := &builder.Spec{
+		Dockerfile: filepath.Join(app.Path, "Dockerfile"),
+		Context:    app.Root,
+		Platforms:  app.Platforms,
+		Tags:       tags,
+		Labels:     labels,
+		Secrets:    secrets,
+
+		InsecureRegistries: app.InsecureRegistries,
+
+		Output: builder.Output{
+			Local:  opts.Local,
+			File:   opts.OutputFile,
+			Format: opts.OutputFormat,
+		},
+	}
+
+	spec.Provenance = opts.Provenance || app.Provenance
+	spec.SBOM = opts.SBOM || app.SBOM
+	if opts.Local && (spec.Provenance || spec.SBOM) {
+		log.Warn("Attestations are only attached to images that are pushed, skipping them")
+		spec.Provenance, spec.SBOM = false, false
+	}
+
+	if err := configureCache(log, b, app, spec, opts); err != nil {
+		log.Fatal(err)
+	}
 
-		err := util.Run(log, "sh", "-c", app.PreBuild)
+	if opts.Reuse && !opts.Local {
+		reused, err := reuseExisting(log, b, app, spec)
 		if err != nil {
-			log.Fatal("Pre-build command failed:", err)
+			log.Fatal(err)
+		}
+
+		if reused != "" {
+			if key != nil {
+				if err := sign(log, app, key, reused); err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			if opts.MetadataFile != "" {
+				if err := writeMetadata(log, opts, app, tags, labels, reused); err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			if err := runHook(log, "postBuild", app.PostBuild, hookEnv(app, reused)); err != nil {
+				log.Fatal("Post-build command failed:", err)
+			}
+
+			log.Success("Successfully reused existing image for", app.Name+":"+app.Tag)
+			return nil
 		}
 	}
 
-	log.Note("Building", app.Name)
+	if referencesSSH(app.PreBuild) {
+		log.Warn("Pre-build command in 'kdeploy.conf' contains reference to '.ssh'.")
+		log.Warn("Please use SSH key forwarding: https://github.com/voormedia/kd#ssh-forwarding")
+	}
+
+	if err := runHook(log, "preBuild", app.PreBuild, hookEnv(app, "")); err != nil {
+		log.Fatal("Pre-build command failed:", err)
+	}
 
-	if err := docker.Build(log, app, writeBuildCache, buildCacheTag, secrets, producer); err != nil {
+	if err := checkContext(log, spec, opts.Strict || app.Strict); err != nil {
 		log.Fatal(err)
 	}
 
-	log.Note("Pushed to", app.Repository())
+	digest, err := b.Build(log, spec)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if app.PostBuild != "" {
-		err := util.Run(log, "sh", "-c", app.PostBuild)
+	if !opts.Local && digest == "" {
+		// Resolve the pushed tag once, so that all later steps refer to the
+		// same image, even if the tag is moved in the meantime.
+		digest, err = pushedDigest(log, app)
 		if err != nil {
-			log.Fatal("Post-build command failed:", err)
+			log.Fatal(err)
+		}
+	}
+
+	if !opts.Local {
+		for _, tag := range tags {
+			log.Note("Pushed to", tag)
+		}
+	} else if opts.OutputFile != "" {
+		log.Note("Exported to", opts.OutputFile)
+	} else {
+		log.Note("Loaded into local image store as", strings.Join(tags, ", "))
+	}
+
+	if len(app.Platforms) > 1 && !opts.Local {
+		if err := verifyPlatforms(log, app, digest); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if key != nil && !opts.Local {
+		if err := sign(log, app, key, digest); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if opts.MetadataFile != "" {
+		if err := writeMetadata(log, opts, app, tags, labels, digest); err != nil {
+			log.Fatal(err)
 		}
 	}
 
+	if err := runHook(log, "postBuild", app.PostBuild, hookEnv(app, digest)); err != nil {
+		log.Fatal("Post-build command failed:", err)
+	}
+
 	log.Success("Successfully built", app.Name+":"+app.Tag)
 	return nil
 }
+
+// Returns the digest of the image that was pushed with the tag of the app, for
+// builders that do not report it.
+func pushedDigest(log *util.Logger, app *config.ResolvedApp) (string, error) {
+	img, err := docker.GetImage(log, app.Repository())
+	if err != nil {
+		return "", err
+	}
+	return img.Descriptor.Digest.String(), nil
+}
+
+// Checks that the pushed image index contains an image for every platform.
+func verifyPlatforms(log *util.Logger, app *config.ResolvedApp, digest string) error {
+	img, err := docker.GetImage(log, app.RepositoryWithDigest(digest))
+	if err != nil {
+		return err
+	}
+
+	platforms, err := docker.ImagePlatforms(log, img)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, platform := range app.Platforms {
+		found := false
+		for _, actual := range platforms {
+			// A platform without variant matches any variant, such as
+			// linux/arm64 and linux/arm64/v8.
+			if actual == platform || strings.HasPrefix(actual, platform+"/") {
+				found = true
+			}
+		}
+
+		if !found {
+			missing = append(missing, platform)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Pushed image %s@%s has no image for %s", app.Name, img.Descriptor.Digest, strings.Join(missing, ", "))
+	}
+
+	log.Note("Pushed image for", strings.Join(platforms, ", "))
+	return nil
+}
+
+// Tags an existing image that was built from the same inputs, if there is one,
+// and returns its digest. Otherwise the fingerprint tag is added to the build,
+// so that the image can be reused by later builds. A reused image keeps the
+// labels it was built with, so its revision label refers to the commit of the
+// earlier build, even though it is also tagged with the current commit.
+func reuseExisting(log *util.Logger, b builder.Builder, app *config.ResolvedApp, spec *builder.Spec) (string, error) {
+	fp, err := fingerprint(log, app, spec, b.Name())
+	if err != nil {
+		log.Warn("Cannot reuse existing image:", err)
+		return "", nil
+	}
+
+	tags := spec.Tags
+	spec.Tags = append(spec.Tags, app.RepositoryWithTag(fp))
+
+	img, err := docker.GetImage(log, app.RepositoryWithTag(fp))
+	if docker.IsNotFound(err) {
+		log.Note("No existing image found for", app.Name+":"+fp)
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	log.Note("Found existing image", app.Name+":"+fp)
+	for _, tag := range tags {
+		if err := docker.TagImage(log, img, tag); err != nil {
+			return "", err
+		}
+		log.Note("Tagged", tag)
+	}
+
+	return img.Descriptor.Digest.String(), nil
+}
+
+// Determines the image tags and OCI labels for a build, so that any pushed
+// image can be traced back to the commit it was built from.
+func imageMetadata(log *util.Logger, app *config.ResolvedApp, opts Options) ([]string, map[string]string, error) {
+	tags := []string{app.Repository()}
+	labels := map[string]string{
+		"producer":                         opts.Producer,
+		"org.opencontainers.image.title":   app.Name,
+		"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if source, err := util.GetRemoteURL(log, app.Root); err == nil && source != "" {
+		labels["org.opencontainers.image.source"] = source
+	}
+
+	if version, err := util.GetDescription(log, app.Root); err == nil {
+		labels["org.opencontainers.image.version"] = version
+	}
+
+	commit, err := util.GetCurrentCommit(log, app.Root)
+	if err != nil {
+		log.Warn("Could not determine git revision:", err)
+		return tags, labels, nil
+	}
+
+	labels["org.opencontainers.image.revision"] = commit
+
+	dirty, err := util.IsWorkingTreeDirty(log, app.Root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not determine whether the working tree has uncommitted changes: %w", err)
+	}
+
+	if dirty {
+		log.Warn("Working tree has uncommitted changes, not tagging image with git revision")
+		return tags, labels, nil
+	}
+
+	tags = append(tags, app.RepositoryWithTag(commit))
+
+	if opts.BranchTag {
+		branch, err := util.GetCurrentBranch(log, app.Path)
+		if err != nil {
+			log.Warn("Could not determine current branch:", err)
+		} else {
+			tags = append(tags, app.RepositoryWithTag(util.Slugify(branch)+"-"+commit[:7]))
+		}
+	}
+
+	return tags, labels, nil
+}
@@ -0,0 +1,116 @@
+package build
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+const fingerprintPrefix = "source-"
+
+// Computes a fingerprint of all inputs of a build: the git objects of all
+// files in the build context that are not excluded by .dockerignore, the
+// Dockerfile, the platform, the ids of secrets, the pre-build command, the
+// attestations and the builder. Images built from the same inputs get the
+// same fingerprint tag. The
+// fingerprint is computed before the pre-build command runs, so files that it
+// generates are not part of it.
+func fingerprint(log *util.Logger, app *config.ResolvedApp, spec *builder.Spec, backend string) (string, error) {
+	dirty, err := util.IsContextDirty(log, app.Root)
+	if err != nil {
+		return "", err
+	}
+
+	if dirty {
+		return "", fmt.Errorf("build context has uncommitted or untracked files")
+	}
+
+	files, err := util.ListTrackedFiles(log, app.Root)
+	if err != nil {
+		return "", err
+	}
+
+	matcher, err := dockerignore(spec)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		if ignored, _ := matcher.MatchesOrParentMatches(path); !ignored {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fmt.Fprintf(hash, "file %s %s\n", path, files[path])
+	}
+
+	dockerfile, err := os.ReadFile(spec.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(hash, "dockerfile %x\n", sha256.Sum256(dockerfile))
+	fmt.Fprintf(hash, "platform %s\n", strings.Join(spec.Platforms, ","))
+	fmt.Fprintf(hash, "ssh %t\n", spec.SSH)
+	fmt.Fprintf(hash, "provenance %t\n", spec.Provenance)
+	fmt.Fprintf(hash, "sbom %t\n", spec.SBOM)
+	fmt.Fprintf(hash, "builder %s\n", backend)
+
+	for _, secret := range spec.Secrets {
+		fmt.Fprintf(hash, "secret %s\n", secret.ID)
+	}
+
+	for _, run := range app.PreBuild.Run {
+		fmt.Fprintf(hash, "prebuild %s\n", run)
+	}
+
+	if app.PreBuild.Script != "" {
+		fmt.Fprintf(hash, "prebuild script %s\n", app.PreBuild.Script)
+	}
+
+	return fingerprintPrefix + hex.EncodeToString(hash.Sum(nil))[:32], nil
+}
+
+// Returns the patterns from the .dockerignore file that applies to the build.
+// Like buildx, a <Dockerfile>.dockerignore file next to the Dockerfile takes
+// precedence over .dockerignore in the root of the build context.
+func dockerignore(spec *builder.Spec) (*patternmatcher.PatternMatcher, error) {
+	candidates := []string{
+		spec.Dockerfile + ".dockerignore",
+		filepath.Join(spec.Context, ".dockerignore"),
+	}
+
+	for _, path := range candidates {
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		patterns, err := ignorefile.ReadAll(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		return patternmatcher.New(patterns)
+	}
+
+	return patternmatcher.New(nil)
+}
@@ -0,0 +1,53 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestFingerprintIncludesAttestationsAndBuilder(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "Dockerfile"), []byte("FROM scratch\n"), 0644)
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "Dockerfile"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+
+	log := util.NewLogger("test")
+	app := &config.ResolvedApp{App: config.App{Name: "my-app", Root: root}}
+	spec := &builder.Spec{
+		Dockerfile: filepath.Join(root, "Dockerfile"),
+		Context:    root,
+		Platforms:  []string{"linux/amd64"},
+	}
+
+	plain, err := fingerprint(log, app, spec, "buildx")
+	assert.Nil(t, err)
+
+	spec.Provenance = true
+	provenance, _ := fingerprint(log, app, spec, "buildx")
+	assert.NotEqual(t, plain, provenance)
+
+	spec.SBOM = true
+	sbom, _ := fingerprint(log, app, spec, "buildx")
+	assert.NotEqual(t, provenance, sbom)
+
+	spec.Provenance, spec.SBOM = false, false
+	again, _ := fingerprint(log, app, spec, "buildx")
+	assert.Equal(t, plain, again)
+
+	podman, _ := fingerprint(log, app, spec, "podman")
+	assert.NotEqual(t, plain, podman)
+}
@@ -0,0 +1,115 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Returns the variables that describe the build to hooks. The digest is only
+// known after the build.
+func hookEnv(app *config.ResolvedApp, digest string) []string {
+	env := []string{
+		"KD_APP=" + app.Name,
+		"KD_TAG=" + app.Tag,
+		"KD_IMAGE=" + app.Repository(),
+		"KD_PLATFORM=" + strings.Join(app.Platforms, ","),
+	}
+
+	if digest != "" {
+		env = append(env, "KD_DIGEST="+digest)
+	}
+
+	return env
+}
+
+/*
+Runs the commands or the script of a hook. Commands run one after another
+in a shell, and the hook stops at the first command that fails. Variables
+of the hook are added to the environment after the build variables, so they
+can override them.
+*/
+func runHook(log *util.Logger, name string, hook config.Hook, env []string) error {
+	if hook.IsEmpty() {
+		return nil
+	}
+
+	ctx := context.Background()
+	if hook.Timeout != "" {
+		timeout, err := time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return err
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	env = append(os.Environ(), env...)
+	keys := make([]string, 0, len(hook.Env))
+	for key := range hook.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+hook.Env[key])
+	}
+
+	var commands [][]string
+	if hook.Script != "" {
+		script, err := filepath.Abs(hook.Script)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, []string{script})
+	} else {
+		for _, run := range hook.Run {
+			commands = append(commands, []string{"sh", "-c", run})
+		}
+	}
+
+	for _, args := range commands {
+		log.Debug("Executing", name, "hook:", strings.Join(args, " "))
+
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = hook.Dir
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader([]byte{})
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if hook.Timeout != "" {
+			killProcessGroup(cmd)
+		}
+
+		if err := cmd.Run(); err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s", hook.Timeout)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reports whether any command of a hook refers to an .ssh directory.
+func referencesSSH(hook config.Hook) bool {
+	for _, run := range append(hook.Run, hook.Script) {
+		if strings.Contains(run, "/.ssh") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package build
+
+import (
+	"os/exec"
+)
+
+// Makes the command stop when it is cancelled. Process groups are not
+// available, so processes started by the command may keep running.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	app := &config.ResolvedApp{
+		App:      config.App{Name: "foo", Platforms: []string{"linux/amd64", "linux/arm64"}},
+		Tag:      "latest",
+		Registry: "registry.example.com",
+	}
+
+	hook := config.Hook{
+		Run: []string{
+			`echo "$KD_APP $KD_TAG $KD_IMAGE $KD_PLATFORM $KD_DIGEST" > out`,
+			`echo "$BUCKET" >> out`,
+		},
+		Dir: dir,
+		Env: map[string]string{"BUCKET": "assets"},
+	}
+
+	err := runHook(util.NewLogger("test"), "postBuild", hook, hookEnv(app, "sha256:0123"))
+	assert.Nil(t, err)
+
+	out, _ := os.ReadFile(filepath.Join(dir, "out"))
+	assert.Equal(t, "foo latest registry.example.com/foo:latest linux/amd64,linux/arm64 sha256:0123\nassets\n", string(out))
+}
+
+func TestRunHookStopsAtFailure(t *testing.T) {
+	dir := t.TempDir()
+	hook := config.Hook{
+		Run: []string{"exit 3", "touch out"},
+		Dir: dir,
+	}
+
+	err := runHook(util.NewLogger("test"), "preBuild", hook, nil)
+	assert.EqualError(t, err, "exit status 3")
+	assert.NoFileExists(t, filepath.Join(dir, "out"))
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	hook := config.Hook{
+		Run:     []string{"sleep 5"},
+		Timeout: "10ms",
+	}
+
+	err := runHook(util.NewLogger("test"), "preBuild", hook, nil)
+	assert.EqualError(t, err, "timed out after 10ms")
+}
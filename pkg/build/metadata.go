@@ -0,0 +1,54 @@
+package build
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// The result of a build, for use by later steps in a CI pipeline.
+type Metadata struct {
+	App       string   `json:"app"`
+	Image     string   `json:"image"`
+	Tags      []string `json:"tags"`
+	Digest    string   `json:"digest,omitempty"`
+	Reference string   `json:"reference,omitempty"`
+	Platforms []string `json:"platforms"`
+	Revision  string   `json:"revision,omitempty"`
+}
+
+func newMetadata(app *config.ResolvedApp, tags []string, labels map[string]string, digest string) Metadata {
+	image := app.Registry + "/" + app.Name
+	metadata := Metadata{
+		App:       app.Name,
+		Image:     image,
+		Digest:    digest,
+		Platforms: app.Platforms,
+		Revision:  labels["org.opencontainers.image.revision"],
+	}
+
+	for _, tag := range tags {
+		metadata.Tags = append(metadata.Tags, strings.TrimPrefix(tag, image+":"))
+	}
+
+	if digest != "" {
+		metadata.Reference = app.RepositoryWithDigest(digest)
+	}
+
+	return metadata
+}
+
+// Writes the result of a build to a JSON file. The digest is empty for images
+// that were not pushed.
+func writeMetadata(log *util.Logger, opts Options, app *config.ResolvedApp, tags []string, labels map[string]string, digest string) error {
+	bytes, err := json.MarshalIndent(newMetadata(app, tags, labels, digest), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	log.Note("Writing build metadata to", opts.MetadataFile)
+	return os.WriteFile(opts.MetadataFile, append(bytes, '\n'), 0644)
+}
@@ -0,0 +1,29 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+)
+
+func TestNewMetadata(t *testing.T) {
+	app := &config.ResolvedApp{
+		App:      config.App{Name: "foo", Platforms: []string{"linux/amd64", "linux/arm64"}},
+		Tag:      "latest",
+		Registry: "registry.example.com",
+	}
+
+	tags := []string{"registry.example.com/foo:latest", "registry.example.com/foo:0123abc"}
+	labels := map[string]string{"org.opencontainers.image.revision": "0123abc"}
+
+	assert.Equal(t, Metadata{
+		App:       "foo",
+		Image:     "registry.example.com/foo",
+		Tags:      []string{"latest", "0123abc"},
+		Digest:    "sha256:4567",
+		Reference: "registry.example.com/foo@sha256:4567",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Revision:  "0123abc",
+	}, newMetadata(app, tags, labels, "sha256:4567"))
+}
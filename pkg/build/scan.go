@@ -0,0 +1,175 @@
+package build
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+const (
+	// Files larger than this are reported as oversized.
+	maxContextFileSize = 100 << 20
+
+	// Number of largest files that are reported.
+	largestContextFiles = 5
+
+	// Only JSON files smaller than this are checked for credentials.
+	maxCredentialsFileSize = 64 << 10
+)
+
+type contextFile struct {
+	Path string
+	Size int64
+}
+
+type contextFinding struct {
+	Path   string
+	Reason string
+}
+
+// The result of scanning the files that are sent to the builder.
+type contextReport struct {
+	Size     int64
+	Files    int
+	Largest  []contextFile
+	Findings []contextFinding
+}
+
+var privateKeyFile = regexp.MustCompile(`^(id_(rsa|dsa|ecdsa|ed25519)|.*\.(pem|key|p12|pfx))$`)
+var envFile = regexp.MustCompile(`^\.env(\..+)?$`)
+var envExampleFile = regexp.MustCompile(`\.(example|sample|template|dist)$`)
+
+/*
+Walks the build context like the builder does, skipping files that are
+excluded by .dockerignore, and collects the size of the context and files
+that are likely secrets or too large.
+*/
+func scanContext(spec *builder.Spec) (*contextReport, error) {
+	matcher, err := dockerignore(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &contextReport{}
+	err = filepath.WalkDir(spec.Context, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(spec.Context, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if ignored, _ := matcher.MatchesOrParentMatches(rel); ignored {
+			if entry.IsDir() && !matcher.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := entry.Name()
+		if entry.IsDir() {
+			if name == ".git" {
+				report.Findings = append(report.Findings, contextFinding{rel, "git repository"})
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		report.Files += 1
+		report.Size += info.Size()
+		report.Largest = append(report.Largest, contextFile{rel, info.Size()})
+
+		if reason := secretReason(path, name, info.Size()); reason != "" {
+			report.Findings = append(report.Findings, contextFinding{rel, reason})
+		} else if info.Size() > maxContextFileSize {
+			report.Findings = append(report.Findings, contextFinding{rel, "oversized file of " + util.FormatSize(info.Size())})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(report.Largest, func(i, j int) bool {
+		return report.Largest[i].Size > report.Largest[j].Size
+	})
+
+	if len(report.Largest) > largestContextFiles {
+		report.Largest = report.Largest[:largestContextFiles]
+	}
+
+	return report, nil
+}
+
+// Returns why a file is likely a secret, or an empty string if it is not.
+func secretReason(path string, name string, size int64) string {
+	switch {
+	case name == ".git":
+		return "git repository"
+	case privateKeyFile.MatchString(name):
+		return "private key"
+	case envFile.MatchString(name) && !envExampleFile.MatchString(name):
+		return "environment file"
+	case name == "credentials" && filepath.Base(filepath.Dir(path)) == ".aws":
+		return "cloud credentials"
+	case strings.HasSuffix(name, ".json") && size < maxCredentialsFileSize:
+		content, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(content), `"private_key"`) {
+			return "cloud credentials"
+		}
+	}
+
+	return ""
+}
+
+/*
+Reports the size of the build context and the files in it that should
+probably not be sent to the builder. Fails if strict checking is enabled and
+any such files are found.
+*/
+func checkContext(log *util.Logger, spec *builder.Spec, strict bool) error {
+	report, err := scanContext(spec)
+	if err != nil {
+		return err
+	}
+
+	log.Note("Build context contains", report.Files, "files of", util.FormatSize(report.Size))
+	if len(report.Largest) > 0 {
+		largest := make([]string, len(report.Largest))
+		for i, file := range report.Largest {
+			largest[i] = file.Path + " (" + util.FormatSize(file.Size) + ")"
+		}
+		log.Log("Largest files:", strings.Join(largest, ", "))
+	}
+
+	if len(report.Findings) == 0 {
+		return nil
+	}
+
+	for _, finding := range report.Findings {
+		log.Warn("Build context contains "+finding.Reason+":", finding.Path)
+	}
+
+	if strict {
+		return fmt.Errorf("Build context contains %d files that should not be sent to the builder, exclude them in .dockerignore", len(report.Findings))
+	}
+
+	log.Warn("Exclude these files in .dockerignore, or build with --strict to fail the build")
+	return nil
+}
@@ -0,0 +1,51 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/internal/builder"
+)
+
+func TestScanContext(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"Dockerfile":              "FROM scratch\n",
+		".dockerignore":           "node_modules\n*.log\n",
+		"app.js":                  "console.log('hello')\n",
+		"server.log":              "secret",
+		".env":                    "TOKEN=secret\n",
+		".env.example":            "TOKEN=\n",
+		"id_ed25519":              "key",
+		"config/account.json":     `{"type": "service_account", "private_key": "key"}`,
+		"config/settings.json":    `{"debug": true}`,
+		"node_modules/.env":       "TOKEN=secret\n",
+		".git/HEAD":               "ref: refs/heads/main\n",
+		"vendor/lib/.git":         "gitdir: ../../.git/modules/lib\n",
+		"public/images/large.png": string(make([]byte, 2048)),
+	}
+
+	for path, content := range files {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755)
+		os.WriteFile(filepath.Join(root, path), []byte(content), 0644)
+	}
+
+	report, err := scanContext(&builder.Spec{
+		Dockerfile: filepath.Join(root, "Dockerfile"),
+		Context:    root,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 11, report.Files)
+	assert.Equal(t, contextFile{"public/images/large.png", 2048}, report.Largest[0])
+	assert.Len(t, report.Largest, 5)
+	assert.ElementsMatch(t, []contextFinding{
+		{".env", "environment file"},
+		{".git", "git repository"},
+		{"config/account.json", "cloud credentials"},
+		{"id_ed25519", "private key"},
+		{"vendor/lib/.git", "git repository"},
+	}, report.Findings)
+}
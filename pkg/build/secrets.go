@@ -0,0 +1,153 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+/*
+Parses the value of a --secret option. Accepts the format of docker buildx,
+such as 'id=npm,env=NPM_TOKEN' or 'id=npm,src=.npmrc'. A secret without a
+source refers to a secret of the app with the same id, or otherwise to the
+environment variable with the same name.
+*/
+func ParseSecret(value string) (config.Secret, error) {
+	var secret config.Secret
+	typ := ""
+
+	for _, field := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			if secret.ID == "" && !strings.Contains(value, ",") {
+				secret.ID = field
+				continue
+			}
+			return secret, fmt.Errorf("Invalid secret '%s', expected key=value pairs", value)
+		}
+
+		switch key {
+		case "id":
+			secret.ID = val
+		case "env":
+			secret.Env = val
+		case "src", "source":
+			secret.File = val
+		case "type":
+			if val != "env" && val != "file" {
+				return secret, fmt.Errorf("Invalid secret '%s', type must be either 'env' or 'file'", value)
+			}
+			typ = val
+		default:
+			return secret, fmt.Errorf("Invalid secret '%s', unknown key '%s'", value, key)
+		}
+	}
+
+	if secret.ID == "" {
+		return secret, fmt.Errorf("Invalid secret '%s', an id is required", value)
+	}
+
+	if secret.Sources() > 1 {
+		return secret, fmt.Errorf("Invalid secret '%s', specify either 'env' or 'src', but not both", value)
+	}
+
+	if typ == "env" && secret.File != "" {
+		// With type=env, src is the name of the environment variable.
+		secret.Env, secret.File = secret.File, ""
+	}
+
+	if typ == "env" && secret.Env == "" {
+		secret.Env = secret.ID
+	} else if typ == "file" && secret.File == "" {
+		return secret, fmt.Errorf("Invalid secret '%s', a file secret requires 'src'", value)
+	}
+
+	return secret, nil
+}
+
+/*
+Combines the secrets of the app with the secrets given on the command line,
+and checks that all of them are available before the build starts. Secrets
+given on the command line take precedence. The output of commands is passed
+to the builder in environment variables, so that it is never written to disk
+and is not visible to other processes that kd starts.
+*/
+func resolveSecrets(log *util.Logger, app *config.ResolvedApp, given []config.Secret) ([]builder.Secret, error) {
+	secrets := append([]config.Secret{}, app.Secrets...)
+
+	for _, secret := range given {
+		i := 0
+		for i < len(secrets) && secrets[i].ID != secret.ID {
+			i++
+		}
+
+		if i == len(secrets) {
+			if secret.Sources() == 0 {
+				secret.Env = secret.ID
+			}
+			secrets = append(secrets, secret)
+		} else if secret.Sources() > 0 {
+			secrets[i] = secret
+		}
+	}
+
+	resolved := make([]builder.Secret, 0, len(secrets))
+	envs := map[string]string{}
+	for _, secret := range secrets {
+		switch {
+		case secret.Env != "":
+			if _, ok := os.LookupEnv(secret.Env); !ok {
+				return nil, fmt.Errorf("Secret '%s' is read from environment variable %s, which is not set", secret.ID, secret.Env)
+			}
+			resolved = append(resolved, builder.Secret{ID: secret.ID, Env: secret.Env})
+
+		case secret.File != "":
+			file := secret.File
+			if rest, ok := strings.CutPrefix(file, "~/"); ok {
+				if home, err := os.UserHomeDir(); err == nil {
+					file = filepath.Join(home, rest)
+				}
+			}
+
+			if _, err := os.Stat(file); err != nil {
+				return nil, fmt.Errorf("Secret '%s' is read from file %s, which cannot be read: %w", secret.ID, secret.File, err)
+			}
+			resolved = append(resolved, builder.Secret{ID: secret.ID, File: file})
+
+		case secret.Command != "":
+			log.Note("Reading secret", secret.ID, "from command")
+			output, err := util.Capture(log, "sh", "-c", secret.Command)
+			if err != nil {
+				return nil, fmt.Errorf("Secret '%s' is read from a command, which failed: %w", secret.ID, err)
+			}
+
+			env := secretEnv(secret.ID)
+			if other, ok := envs[env]; ok {
+				return nil, fmt.Errorf("Secrets '%s' and '%s' are both read from a command and cannot be told apart, rename one of them", other, secret.ID)
+			}
+			envs[env] = secret.ID
+
+			resolved = append(resolved, builder.Secret{
+				ID:    secret.ID,
+				Env:   env,
+				Value: strings.TrimRight(string(output), "\r\n"),
+			})
+		}
+	}
+
+	return resolved, nil
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// Returns the environment variable that exposes the output of the command of
+// a secret to the builder.
+func secretEnv(id string) string {
+	return "KD_SECRET_" + nonAlphanumeric.ReplaceAllString(strings.ToUpper(id), "_")
+}
@@ -0,0 +1,102 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestParseSecret(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out config.Secret
+	}{
+		{"id=npm,env=NPM_TOKEN", config.Secret{ID: "npm", Env: "NPM_TOKEN"}},
+		{"id=npm,src=/home/me/.npmrc", config.Secret{ID: "npm", File: "/home/me/.npmrc"}},
+		{"id=npm,source=.npmrc,type=file", config.Secret{ID: "npm", File: ".npmrc"}},
+		{"id=npm,src=NPM_TOKEN,type=env", config.Secret{ID: "npm", Env: "NPM_TOKEN"}},
+		{"id=NPM_TOKEN,type=env", config.Secret{ID: "NPM_TOKEN", Env: "NPM_TOKEN"}},
+		{"id=npm", config.Secret{ID: "npm"}},
+		{"npm", config.Secret{ID: "npm"}},
+	}
+
+	for _, tc := range testCases {
+		secret, err := ParseSecret(tc.in)
+		assert.Nil(t, err, tc.in)
+		assert.Equal(t, tc.out, secret, tc.in)
+	}
+}
+
+func TestParseInvalidSecret(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err string
+	}{
+		{"env=NPM_TOKEN", "Invalid secret 'env=NPM_TOKEN', an id is required"},
+		{"id=npm,env=NPM_TOKEN,src=.npmrc", "Invalid secret 'id=npm,env=NPM_TOKEN,src=.npmrc', specify either 'env' or 'src', but not both"},
+		{"id=npm,type=file", "Invalid secret 'id=npm,type=file', a file secret requires 'src'"},
+		{"id=npm,type=ssh", "Invalid secret 'id=npm,type=ssh', type must be either 'env' or 'file'"},
+		{"id=npm,required", "Invalid secret 'id=npm,required', expected key=value pairs"},
+		{"id=npm,mode=0400", "Invalid secret 'id=npm,mode=0400', unknown key 'mode'"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseSecret(tc.in)
+		assert.EqualError(t, err, tc.err, tc.in)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".npmrc")
+	os.WriteFile(file, []byte("token"), 0600)
+	t.Setenv("NPM_TOKEN", "token")
+	t.Setenv("GEM_TOKEN", "token")
+
+	app := &config.ResolvedApp{App: config.App{
+		Secrets: []config.Secret{
+			{ID: "npm", Env: "NPM_TOKEN"},
+			{ID: "gem", Command: "echo 'gem token'"},
+		},
+	}}
+
+	secrets, err := resolveSecrets(util.NewLogger("test"), app, []config.Secret{
+		{ID: "npm", File: file},
+		{ID: "gem"},
+		{ID: "GEM_TOKEN"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []builder.Secret{
+		{ID: "npm", File: file},
+		{ID: "gem", Env: "KD_SECRET_GEM", Value: "gem token"},
+		{ID: "GEM_TOKEN", Env: "GEM_TOKEN"},
+	}, secrets)
+	_, set := os.LookupEnv("KD_SECRET_GEM")
+	assert.False(t, set)
+}
+
+func TestResolveMissingSecret(t *testing.T) {
+	app := &config.ResolvedApp{App: config.App{
+		Secrets: []config.Secret{{ID: "npm", Env: "KD_TEST_MISSING"}},
+	}}
+
+	_, err := resolveSecrets(util.NewLogger("test"), app, nil)
+	assert.EqualError(t, err, "Secret 'npm' is read from environment variable KD_TEST_MISSING, which is not set")
+}
+
+func TestResolveCollidingSecrets(t *testing.T) {
+	app := &config.ResolvedApp{App: config.App{
+		Secrets: []config.Secret{
+			{ID: "npm-token", Command: "echo token"},
+			{ID: "npm_token", Command: "echo token"},
+		},
+	}}
+
+	_, err := resolveSecrets(util.NewLogger("test"), app, nil)
+	assert.EqualError(t, err, "Secrets 'npm-token' and 'npm_token' are both read from a command and cannot be told apart, rename one of them")
+}
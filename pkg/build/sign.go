@@ -0,0 +1,70 @@
+package build
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/internal/signing"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Returns the key to sign images with, or nil if signing is not configured.
+// A key that is read from an environment variable is optional, so that
+// images can still be built outside of CI.
+func signingKey(log *util.Logger, app *config.ResolvedApp) (*ecdsa.PrivateKey, error) {
+	conf := app.Signing
+	if conf.Key != "" {
+		return signing.ReadPrivateKey(conf.Key)
+	}
+
+	if conf.KeyEnv != "" {
+		value := os.Getenv(conf.KeyEnv)
+		if value == "" {
+			log.Warn("Images will not be signed, because", conf.KeyEnv, "is not set")
+			return nil, nil
+		}
+		return signing.ParsePrivateKey([]byte(value))
+	}
+
+	return nil, nil
+}
+
+// Signs the image with the given digest, unless it was already signed with
+// the key. Images are never looked up by tag, so that only the image that was
+// pushed can be signed.
+func sign(log *util.Logger, app *config.ResolvedApp, key *ecdsa.PrivateKey, digest string) error {
+	if digest == "" {
+		return fmt.Errorf("Cannot sign %s without the digest of the pushed image", app.Name)
+	}
+
+	img, err := docker.GetImage(log, app.RepositoryWithDigest(digest))
+	if err != nil {
+		return err
+	}
+
+	dgst := img.Descriptor.Digest.String()
+	existing, err := docker.GetSignatures(log, img)
+	if err != nil {
+		return err
+	}
+
+	if signing.Verify([]*ecdsa.PublicKey{&key.PublicKey}, dgst, existing) {
+		log.Note("Image is already signed", app.Name+"@"+dgst)
+		return nil
+	}
+
+	sig, err := signing.Sign(key, img.Named.Name(), dgst)
+	if err != nil {
+		return err
+	}
+
+	if err := docker.AddSignature(log, img, sig); err != nil {
+		return err
+	}
+
+	log.Note("Signed", app.Name+"@"+dgst)
+	return nil
+}
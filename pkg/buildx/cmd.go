@@ -0,0 +1,73 @@
+package buildx
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/builder"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+type Status struct {
+	builder.InstanceStatus
+	Exists   bool     `json:"exists"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Returns the state of the configured builder, or of the currently selected
+// builder if none is configured, and how it differs from the configuration.
+func Inspect(log *util.Logger, conf config.BuildxBuilder) (*Status, error) {
+	instance, err := builder.InspectInstance(log, conf.Name)
+	if err != nil {
+		if conf.IsEmpty() {
+			return nil, err
+		}
+
+		log.Debug(err)
+		return &Status{
+			InstanceStatus: builder.InstanceStatus{Name: conf.Name, Driver: conf.Driver, Status: "missing"},
+			Problems:       []string{"builder does not exist, it will be created by 'kd build' or 'kd builder create'"},
+		}, nil
+	}
+
+	status := &Status{InstanceStatus: *instance, Exists: true}
+
+	if !conf.IsEmpty() && instance.Driver != conf.Driver {
+		status.Problems = append(status.Problems, fmt.Sprintf("builder uses driver %s instead of %s", instance.Driver, conf.Driver))
+	}
+
+	for _, platform := range conf.Platforms {
+		if !slices.Contains(instance.Platforms, platform) {
+			status.Problems = append(status.Problems, fmt.Sprintf("builder does not support platform %s", platform))
+		}
+	}
+
+	if !instance.CacheExport {
+		status.Problems = append(status.Problems, "builder cannot export build cache to the registry")
+	}
+
+	return status, nil
+}
+
+func Create(log *util.Logger, conf config.BuildxBuilder) error {
+	if conf.IsEmpty() {
+		return fmt.Errorf("No buildx builder is configured in %s", config.ConfigName)
+	}
+
+	if _, err := builder.InspectInstance(log, conf.Name); err == nil {
+		return fmt.Errorf("Builder %s already exists, remove it first with 'kd builder rm'", conf.Name)
+	}
+
+	log.Note("Creating buildx builder", conf.Name, "with driver", conf.Driver)
+	return builder.CreateInstance(log, conf)
+}
+
+func Remove(log *util.Logger, conf config.BuildxBuilder) error {
+	if conf.IsEmpty() {
+		return fmt.Errorf("No buildx builder is configured in %s", config.ConfigName)
+	}
+
+	log.Note("Removing buildx builder", conf.Name)
+	return builder.RemoveInstance(log, conf.Name)
+}
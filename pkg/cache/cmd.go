@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+type Policy struct {
+	// Cache images older than this are stale, regardless of their branch. Zero
+	// means cache images never expire.
+	MaxAge time.Duration
+}
+
+type Entry struct {
+	docker.Tag
+	Reason  string `json:"reason"`
+	Deleted bool   `json:"deleted"`
+}
+
+// Determines which build cache images of the app are stale: images of
+// branches that no longer exist, or images older than the maximum age. Cache
+// images of default branches are never stale.
+func Plan(log *util.Logger, app *config.ResolvedApp, policy Policy) ([]Entry, error) {
+	branches, err := util.ListBranches(log, app.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, branch := range branches {
+		branches[i] = util.Slugify(branch)
+	}
+
+	defaults := make([]string, len(app.DefaultBranch))
+	for i, branch := range app.DefaultBranch {
+		defaults[i] = util.Slugify(branch)
+	}
+
+	log.Note("Retrieving build cache images of", app.Name)
+	tags, err := docker.ListTags(log, app.BuildCacheRepository())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stale := map[string][]Entry{}
+	kept := map[string]bool{}
+	unknown := 0
+
+	for _, tag := range tags {
+		reason := ""
+		if !matchesBranch(tag.Name, defaults) {
+			if !matchesBranch(tag.Name, branches) {
+				reason = "branch deleted"
+			} else if policy.MaxAge > 0 && tag.Created == nil {
+				unknown++
+			} else if policy.MaxAge > 0 && now.Sub(*tag.Created) > policy.MaxAge {
+				reason = fmt.Sprintf("older than %s", util.FormatAge(policy.MaxAge))
+			}
+		}
+
+		if reason == "" {
+			kept[tag.Digest] = true
+		} else {
+			stale[tag.Digest] = append(stale[tag.Digest], Entry{Tag: tag, Reason: reason})
+		}
+	}
+
+	if unknown > 0 {
+		log.Warn("The age of", unknown, "build cache images is unknown, they are not deleted for their age")
+	}
+
+	var plan []Entry
+	for digest, entries := range stale {
+		// Deleting a manifest deletes all its tags, so keep images that are
+		// still referred to by any tag that is not stale.
+		if kept[digest] {
+			continue
+		}
+		plan = append(plan, entries...)
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Name < plan[j].Name
+	})
+
+	return plan, nil
+}
+
+// Deletes the cache images in the plan from the registry.
+func Prune(log *util.Logger, app *config.ResolvedApp, plan []Entry) error {
+	tags := map[string][]string{}
+	var digests []string
+	for _, entry := range plan {
+		if tags[entry.Digest] == nil {
+			digests = append(digests, entry.Digest)
+		}
+		tags[entry.Digest] = append(tags[entry.Digest], entry.Name)
+	}
+
+	for _, digest := range digests {
+		log.Note("Deleting", strings.Join(tags[digest], ", "))
+		if err := docker.DeleteTags(log, app.BuildCacheRepository(), digest, tags[digest]); err != nil {
+			return err
+		}
+
+		for i := range plan {
+			if plan[i].Digest == digest {
+				plan[i].Deleted = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// Cache tags consist of a slugified branch name, optionally followed by
+// components for SSH forwarding and secrets.
+func matchesBranch(tag string, branches []string) bool {
+	for _, branch := range branches {
+		if tag == branch || strings.HasPrefix(tag, branch+"-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesBranch(t *testing.T) {
+	branches := []string{"main", "feature-login"}
+
+	assert.True(t, matchesBranch("main", branches))
+	assert.True(t, matchesBranch("main-ssh-npm-token", branches))
+	assert.True(t, matchesBranch("feature-login-ssh", branches))
+	assert.False(t, matchesBranch("feature-logout", branches))
+	assert.False(t, matchesBranch("mainline", branches))
+}
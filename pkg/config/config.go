@@ -3,17 +3,28 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
+	"github.com/voormedia/kd/pkg/util"
 	"gopkg.in/yaml.v3"
 )
 
 type ResolvedApp struct {
 	App
-	Tag      string
-	Registry string
-	Cache    string
+	Tag           string
+	Digest        string
+	Registry      string
+	Cache         string
+	DefaultBranch []string
+	BuildxBuilder BuildxBuilder
+	Signing       Signing
+	Retention     Retention
+
+	// Hosts of registries that are accessed over plain HTTP.
+	InsecureRegistries []string
 }
 
 type ResolvedTarget struct {
@@ -21,8 +32,22 @@ type ResolvedTarget struct {
 }
 
 const DefaultTag = "latest"
+const DefaultPlatform = "linux/amd64"
+const DefaultBuilderName = "kd"
+const DefaultBuilderDriver = "docker-container"
+const DefaultBranch = "main"
 const ConfigName = "kdeploy.conf"
 
+/*
+Sources of build cache that can be listed in 'cacheFrom'. Any other value is
+interpreted as the name of a branch.
+*/
+const (
+	CacheFromBranch    = "branch"
+	CacheFromMergeBase = "merge-base"
+	CacheFromDefault   = "default"
+)
+
 func Load() (*Config, error) {
 	return LoadFromFs(&afero.Afero{Fs: afero.NewOsFs()})
 }
@@ -41,6 +66,10 @@ func LoadFromFs(afs *afero.Afero) (*Config, error) {
 		return nil, errors.Errorf("Unsupported configuration version %d, please run 'kd upgrade' to upgrade to version %d", conf.ApiVersion, LatestVersion)
 	}
 
+	if len(conf.DefaultBranch) == 0 {
+		conf.DefaultBranch = []string{DefaultBranch}
+	}
+
 	defaults := 0
 	for i := range conf.Apps {
 		app := &conf.Apps[i]
@@ -58,8 +87,30 @@ func LoadFromFs(afs *afero.Afero) (*Config, error) {
 			app.Root = app.Path
 		}
 
-		if app.Platform == "" {
-			app.Platform = "linux/amd64"
+		if len(app.Platforms) == 0 {
+			app.Platforms = []string{DefaultPlatform}
+		}
+
+		if len(app.CacheFrom) == 0 {
+			app.CacheFrom = []string{CacheFromBranch, CacheFromDefault}
+		}
+
+		if err := validateSecrets(app); err != nil {
+			return nil, err
+		}
+
+		if err := app.PreBuild.validate(app.Name, "preBuild"); err != nil {
+			return nil, err
+		}
+
+		if err := app.PostBuild.validate(app.Name, "postBuild"); err != nil {
+			return nil, err
+		}
+
+		if app.CacheMode == "" {
+			app.CacheMode = "max"
+		} else if app.CacheMode != "max" && app.CacheMode != "min" {
+			return nil, fmt.Errorf("Cache mode of '%s' must be either 'max' or 'min'", app.Name)
 		}
 	}
 
@@ -67,9 +118,145 @@ func LoadFromFs(afs *afero.Afero) (*Config, error) {
 		return nil, fmt.Errorf("Only one application may be marked with 'default: true'")
 	}
 
+	if !conf.BuildxBuilder.IsEmpty() {
+		if conf.BuildxBuilder.Name == "" {
+			conf.BuildxBuilder.Name = DefaultBuilderName
+		}
+
+		if conf.BuildxBuilder.Driver == "" {
+			conf.BuildxBuilder.Driver = DefaultBuilderDriver
+		} else if conf.BuildxBuilder.Driver == "docker" {
+			return nil, fmt.Errorf("Builder driver 'docker' cannot be used, because it does not support registry cache")
+		}
+	}
+
+	if conf.Signing.Key != "" && conf.Signing.KeyEnv != "" {
+		return nil, fmt.Errorf("Specify a signing key either with 'key' or with 'keyEnv', but not both")
+	}
+
+	if err := validateRegistries(conf.Registries); err != nil {
+		return nil, err
+	}
+
+	if conf.Retention.Keep < 0 {
+		return nil, fmt.Errorf("Retention 'keep' must not be negative")
+	}
+
+	if conf.Retention.MaxAge != "" {
+		if _, err := util.ParseAge(conf.Retention.MaxAge); err != nil {
+			return nil, fmt.Errorf("Retention has an invalid 'maxAge' '%s'", conf.Retention.MaxAge)
+		}
+	}
+
+	for _, target := range conf.Targets {
+		if target.RequireSignature && len(conf.Signing.PublicKeys) == 0 {
+			return nil, fmt.Errorf("Target '%s' requires signatures, but no 'publicKeys' are configured in 'signing'", target.Name)
+		}
+	}
+
 	return conf, nil
 }
 
+func validateSecrets(app *App) error {
+	ids := map[string]bool{}
+	for _, secret := range app.Secrets {
+		if secret.ID == "" {
+			return fmt.Errorf("Secrets of '%s' must have an 'id'", app.Name)
+		}
+
+		if ids[secret.ID] {
+			return fmt.Errorf("Secret '%s' of '%s' is declared more than once", secret.ID, app.Name)
+		}
+		ids[secret.ID] = true
+
+		if secret.Sources() != 1 {
+			return fmt.Errorf("Secret '%s' of '%s' must have exactly one of 'env', 'file' or 'command'", secret.ID, app.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateRegistries(registries []RegistryOptions) error {
+	hosts := map[string]bool{}
+	for _, registry := range registries {
+		if registry.Host == "" {
+			return fmt.Errorf("Registries must have a 'host'")
+		}
+
+		if hosts[registry.Host] {
+			return fmt.Errorf("Registry '%s' is configured more than once", registry.Host)
+		}
+		hosts[registry.Host] = true
+
+		if registry.CredentialSources() > 1 {
+			return fmt.Errorf("Registry '%s' must have at most one of 'credentialHelper', 'tokenEnv', 'keyFile' or 'anonymous'", registry.Host)
+		}
+
+		if registry.Username != "" && registry.TokenEnv == "" {
+			return fmt.Errorf("Registry '%s' has a 'username', which can only be used with 'tokenEnv'", registry.Host)
+		}
+	}
+
+	return nil
+}
+
+// Returns the number of sources the credentials for the registry are read
+// from.
+func (registry RegistryOptions) CredentialSources() int {
+	sources := 0
+	for _, source := range []string{registry.CredentialHelper, registry.TokenEnv, registry.KeyFile} {
+		if source != "" {
+			sources += 1
+		}
+	}
+	if registry.Anonymous {
+		sources += 1
+	}
+	return sources
+}
+
+// Returns the number of sources the value of the secret is read from.
+func (secret Secret) Sources() int {
+	sources := 0
+	for _, source := range []string{secret.Env, secret.File, secret.Command} {
+		if source != "" {
+			sources += 1
+		}
+	}
+	return sources
+}
+
+func (hook *Hook) validate(app string, name string) error {
+	if len(hook.Run) > 0 && hook.Script != "" {
+		return fmt.Errorf("Hook '%s' of '%s' must have either 'run' or 'script', but not both", name, app)
+	}
+
+	if hook.Timeout != "" {
+		if _, err := time.ParseDuration(hook.Timeout); err != nil {
+			return fmt.Errorf("Hook '%s' of '%s' has an invalid timeout '%s'", name, app, hook.Timeout)
+		}
+	}
+
+	return nil
+}
+
+// Reports whether the hook has anything to run.
+func (hook *Hook) IsEmpty() bool {
+	return len(hook.Run) == 0 && hook.Script == ""
+}
+
+// Reports whether no retention policy is configured.
+func (retention *Retention) IsEmpty() bool {
+	return retention.Keep == 0 && retention.MaxAge == ""
+}
+
+// Reports whether no buildx builder is configured, in which case the currently
+// selected builder is used.
+func (builder *BuildxBuilder) IsEmpty() bool {
+	return builder.Name == "" && builder.Driver == "" && len(builder.Platforms) == 0 && len(builder.DriverOpts) == 0
+}
+
 func GetRawFromFS(afs *afero.Afero) (*Config, error) {
 	bytes, err := afs.ReadFile(ConfigName)
 	if err != nil {
@@ -93,9 +280,20 @@ func (conf *Config) AppNames() (names []string) {
 
 func (conf *Config) ResolveApp(name string, tag string) (*ResolvedApp, error) {
 	/* TODO: No naming conflicts are checked yet. Returns the first match. */
+	name, dgst, byDigest := strings.Cut(name, "@")
 	parts := strings.Split(name, ":")
 	name = parts[0]
 
+	if byDigest {
+		if _, err := digest.Parse(dgst); err != nil {
+			return nil, fmt.Errorf("Invalid digest '%s'", dgst)
+		}
+
+		if tag != "" || len(parts) > 1 {
+			return nil, fmt.Errorf("Specify either a tag or a digest, but not both")
+		}
+	}
+
 	if tag == "" {
 		tag = DefaultTag
 		if len(parts) > 1 {
@@ -108,10 +306,17 @@ func (conf *Config) ResolveApp(name string, tag string) (*ResolvedApp, error) {
 	for _, app := range conf.Apps {
 		if (name == "" && (app.Default || len(conf.Apps) == 1)) || name == app.Name {
 			return &ResolvedApp{
-				App:      app,
-				Tag:      tag,
-				Registry: conf.Registry,
-				Cache:    conf.Cache,
+				App:           app,
+				Tag:           tag,
+				Digest:        dgst,
+				Registry:      conf.Registry,
+				Cache:         conf.Cache,
+				DefaultBranch: conf.DefaultBranch,
+				BuildxBuilder: conf.BuildxBuilder,
+				Signing:       conf.Signing,
+				Retention:     conf.Retention,
+
+				InsecureRegistries: InsecureRegistries(conf.Registries),
 			}, nil
 		}
 	}
@@ -127,6 +332,16 @@ func (conf *Config) ResolveApp(name string, tag string) (*ResolvedApp, error) {
 	return nil, fmt.Errorf("Unknown application '%s'", name)
 }
 
+// Returns the hosts of registries that are accessed over plain HTTP.
+func InsecureRegistries(registries []RegistryOptions) (hosts []string) {
+	for _, registry := range registries {
+		if registry.Insecure {
+			hosts = append(hosts, registry.Host)
+		}
+	}
+	return
+}
+
 func (conf *Config) TargetNames() (names []string) {
 	for _, tgt := range conf.Targets {
 		names = append(names, tgt.Name)
@@ -147,21 +362,50 @@ func (conf *Config) ResolveTarget(name string) (*ResolvedTarget, error) {
 	return nil, fmt.Errorf("Unknown target '%s'", name)
 }
 
+// Returns the image the app refers to, by digest if one was given, or by tag.
 func (app *ResolvedApp) Repository() string {
+	if app.Digest != "" {
+		return app.RepositoryWithDigest(app.Digest)
+	}
 	return app.RepositoryWithTag(app.Tag)
 }
 
+// Returns the name of the app with its digest or tag, for display.
+func (app *ResolvedApp) Reference() string {
+	if app.Digest != "" {
+		return app.Name + "@" + app.Digest
+	}
+	return app.Name + ":" + app.Tag
+}
+
 func (app *ResolvedApp) RepositoryBuildCache(tag string) string {
 	if tag == "" {
 		tag = app.Tag
 	}
 
+	return app.BuildCacheRepository() + ":" + tag
+}
+
+func (app *ResolvedApp) BuildCacheRepository() string {
 	// Use custom cache location if specified.
 	if app.Cache != "" {
-		return app.Cache + "/" + app.Name + ":" + tag
+		return app.Cache + "/" + app.Name
+	} else if isDockerHub(app.Registry) {
+		// Docker Hub does not support nested repositories.
+		return app.Registry + "/" + app.Name + "-build-cache"
 	} else {
-		return app.Registry + "/" + app.Name + "/build-cache" + ":" + tag
+		return app.Registry + "/" + app.Name + "/build-cache"
+	}
+}
+
+// Reports whether images in the registry are stored on Docker Hub. Like in
+// image references, the first component is only a host if it looks like one.
+func isDockerHub(registry string) bool {
+	host, _, _ := strings.Cut(registry, "/")
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host == "docker.io" || host == "index.docker.io"
 	}
+	return true
 }
 
 func (app *ResolvedApp) RepositoryWithTag(tag string) string {
@@ -172,6 +416,14 @@ func (app *ResolvedApp) RepositoryWithDigest(digest string) string {
 	return app.Registry + "/" + app.Name + "@" + digest
 }
 
+// Returns the repository that the target pulls images of the app from.
+func (app *ResolvedApp) TargetRepository(target *ResolvedTarget) string {
+	if target.Registry != "" {
+		return target.Registry + "/" + app.Name
+	}
+	return app.Registry + "/" + app.Name
+}
+
 func (target *ResolvedTarget) GCPProject() string {
 	// Context looks like: gke_voormedia-187708_europe-west1-b_voormedia-2
 	parts := strings.Split(target.Target.Context, "_")
@@ -194,6 +446,17 @@ func (a *StringArray) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+func (hook *Hook) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var run StringArray
+	if err := unmarshal(&run); err == nil {
+		*hook = Hook{Run: run}
+		return nil
+	}
+
+	type plain Hook
+	return unmarshal((*plain)(hook))
+}
+
 func stupidContains(slice []string, search string) bool {
 	for _, item := range slice {
 		if item == search {
@@ -46,20 +46,25 @@ func TestLoad(t *testing.T) {
 	assert.Nil(t, err)
 
 	expected := &Config{
-		ApiVersion: LatestVersion,
-		Registry:   "eu.gcr.io/project-123456/a-customer-name",
+		ApiVersion:    LatestVersion,
+		Registry:      "eu.gcr.io/project-123456/a-customer-name",
+		DefaultBranch: []string{"main"},
 		Apps: []App{{
-			Name:     "my-website",
-			Path:     ".",
-			Root:     ".",
-			Default:  true,
-			Platform: "linux/amd64",
+			Name:      "my-website",
+			Path:      ".",
+			Root:      ".",
+			Default:   true,
+			Platforms: []string{"linux/amd64"},
+			CacheFrom: []string{"branch", "default"},
+			CacheMode: "max",
 		}, {
-			Name:     "other-app",
-			Path:     "apps/other-app",
-			Root:     "apps",
-			Platform: "linux/amd64",
-			PreBuild: "script/foo.sh",
+			Name:      "other-app",
+			Path:      "apps/other-app",
+			Root:      "apps",
+			Platforms: []string{"linux/amd64"},
+			PreBuild:  Hook{Run: []string{"script/foo.sh"}},
+			CacheFrom: []string{"branch", "default"},
+			CacheMode: "max",
 		}},
 		Targets: []Target{{
 			Name:      "acceptance",
@@ -78,6 +83,181 @@ func TestLoad(t *testing.T) {
 	assert.Equal(t, expected, conf)
 }
 
+func TestLoadCacheOptions(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	fs.WriteFile("kdeploy.conf", []byte(strings.Join([]string{
+		"version: 2\n",
+		"defaultBranch: [develop, master]\n",
+		"apps:\n",
+		"- name: my-website\n",
+		"  path: .\n",
+		"  cacheFrom: [branch, merge-base, release, default]\n",
+		"  cacheMode: min\n",
+	}, "")), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, StringArray{"develop", "master"}, conf.DefaultBranch)
+	assert.Equal(t, StringArray{"branch", "merge-base", "release", "default"}, conf.Apps[0].CacheFrom)
+	assert.Equal(t, "min", conf.Apps[0].CacheMode)
+
+	app, err := conf.ResolveApp("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"develop", "master"}, app.DefaultBranch)
+}
+
+func TestLoadInvalidCacheMode(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\napps:\n- name: foo\n  cacheMode: all\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Cache mode of 'foo' must be either 'max' or 'min'", err.Error())
+}
+
+func TestLoadSecrets(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte(`version: 2
+apps:
+- name: foo
+  secrets:
+  - id: npm
+    env: NPM_TOKEN
+  - id: gem
+    command: cat ~/.gem/credentials
+`), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, []Secret{
+		{ID: "npm", Env: "NPM_TOKEN"},
+		{ID: "gem", Command: "cat ~/.gem/credentials"},
+	}, conf.Apps[0].Secrets)
+}
+
+func TestLoadSecretWithoutSource(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\napps:\n- name: foo\n  secrets:\n  - id: npm\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Secret 'npm' of 'foo' must have exactly one of 'env', 'file' or 'command'", err.Error())
+}
+
+func TestLoadSecretWithoutID(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\napps:\n- name: foo\n  secrets:\n  - env: NPM_TOKEN\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Secrets of 'foo' must have an 'id'", err.Error())
+}
+
+func TestLoadHooks(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte(`version: 2
+apps:
+- name: foo
+  preBuild:
+  - yarn install
+  - yarn build
+  postBuild:
+    script: script/upload-assets.sh
+    dir: public
+    timeout: 5m
+    env:
+      BUCKET: assets
+`), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, Hook{Run: []string{"yarn install", "yarn build"}}, conf.Apps[0].PreBuild)
+	assert.Equal(t, Hook{
+		Script:  "script/upload-assets.sh",
+		Dir:     "public",
+		Timeout: "5m",
+		Env:     map[string]string{"BUCKET": "assets"},
+	}, conf.Apps[0].PostBuild)
+}
+
+func TestLoadInvalidHookTimeout(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\napps:\n- name: foo\n  postBuild:\n    run: echo\n    timeout: soon\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Hook 'postBuild' of 'foo' has an invalid timeout 'soon'", err.Error())
+}
+
+func TestLoadBuilder(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\nbuildxBuilder:\n  platforms: [linux/amd64, linux/arm64]\n  driverOpts:\n    network: host\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, BuildxBuilder{
+		Name:       "kd",
+		Driver:     "docker-container",
+		Platforms:  []string{"linux/amd64", "linux/arm64"},
+		DriverOpts: map[string]string{"network": "host"},
+	}, conf.BuildxBuilder)
+}
+
+func TestLoadRequireSignatureWithoutPublicKeys(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\ntargets:\n- name: production\n  requireSignature: true\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Target 'production' requires signatures, but no 'publicKeys' are configured in 'signing'", err.Error())
+}
+
+func TestLoadRegistries(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte(strings.Join([]string{
+		"version: 2\n",
+		"registries:\n",
+		"- host: europe-docker.pkg.dev\n",
+		"  credentialHelper: gcloud\n",
+		"- host: ghcr.io\n",
+		"  tokenEnv: GITHUB_TOKEN\n",
+		"  username: voormedia\n",
+		"- host: docker.io\n",
+		"  anonymous: true\n",
+		"- host: localhost:5000\n",
+		"  insecure: true\n",
+	}, "")), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, []RegistryOptions{
+		{Host: "europe-docker.pkg.dev", CredentialHelper: "gcloud"},
+		{Host: "ghcr.io", TokenEnv: "GITHUB_TOKEN", Username: "voormedia"},
+		{Host: "docker.io", Anonymous: true},
+		{Host: "localhost:5000", Insecure: true},
+	}, conf.Registries)
+	assert.Equal(t, []string{"localhost:5000"}, InsecureRegistries(conf.Registries))
+}
+
+func TestLoadRegistryWithMultipleCredentialSources(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\nregistries:\n- host: ghcr.io\n  tokenEnv: GITHUB_TOKEN\n  anonymous: true\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Registry 'ghcr.io' must have at most one of 'credentialHelper', 'tokenEnv', 'keyFile' or 'anonymous'", err.Error())
+}
+
+func TestLoadInvalidRetention(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("kdeploy.conf", []byte("version: 2\nretention:\n  keep: 10\n  maxAge: a month\n"), 0644)
+
+	conf, err := LoadFromFs(fs)
+	assert.Nil(t, conf)
+	assert.Equal(t, "Retention has an invalid 'maxAge' 'a month'", err.Error())
+}
+
 func TestLoadError(t *testing.T) {
 	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
 	fs.WriteFile("kdeploy.conf", []byte("Bad file format"), 0644)
@@ -229,6 +409,42 @@ func TestResolveExistingAppExplicitLatest(t *testing.T) {
 	assert.Equal(t, "my.registry.com/foo:latest", app.Repository())
 }
 
+func TestResolveExistingAppDigest(t *testing.T) {
+	conf := &Config{
+		Registry: "my.registry.com",
+		Apps: []App{{
+			Name: "foo",
+			Path: "apps/foo",
+		}},
+	}
+
+	dgst := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	app, err := conf.ResolveApp("foo@"+dgst, "")
+	assert.Nil(t, err)
+	assert.Equal(t, dgst, app.Digest)
+	assert.Equal(t, "my.registry.com/foo@"+dgst, app.Repository())
+	assert.Equal(t, "foo@"+dgst, app.Reference())
+}
+
+func TestResolveExistingAppInvalidDigest(t *testing.T) {
+	conf := &Config{
+		Registry: "my.registry.com",
+		Apps: []App{{
+			Name: "foo",
+			Path: "apps/foo",
+		}},
+	}
+
+	app, err := conf.ResolveApp("foo@sha256:0123", "")
+	assert.Nil(t, app)
+	assert.Equal(t, "Invalid digest 'sha256:0123'", err.Error())
+
+	dgst := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	app, err = conf.ResolveApp("foo@"+dgst, "my-tag")
+	assert.Nil(t, app)
+	assert.Equal(t, "Specify either a tag or a digest, but not both", err.Error())
+}
+
 func TestResolveMissingApp(t *testing.T) {
 	conf := &Config{
 		Registry: "my.registry.com",
@@ -367,3 +583,32 @@ func TestRepositoryBuildCacheDefault(t *testing.T) {
 	assert.Equal(t, "registry.example.com/foo/build-cache:my-tag", app.RepositoryBuildCache("my-tag"))
 	assert.Equal(t, "registry.example.com/foo/build-cache:other-tag", app.RepositoryBuildCache("other-tag"))
 }
+
+func TestTargetRepository(t *testing.T) {
+	app := &ResolvedApp{
+		App:      App{Name: "foo"},
+		Registry: "registry.example.com",
+	}
+
+	target := &ResolvedTarget{Target: Target{Name: "production"}}
+	assert.Equal(t, "registry.example.com/foo", app.TargetRepository(target))
+
+	target.Registry = "registry.customer.com/images"
+	assert.Equal(t, "registry.customer.com/images/foo", app.TargetRepository(target))
+}
+
+func TestRepositoryBuildCacheDockerHub(t *testing.T) {
+	app := &ResolvedApp{
+		App:      App{Name: "foo"},
+		Tag:      "my-tag",
+		Registry: "docker.io/my-org",
+	}
+
+	assert.Equal(t, "docker.io/my-org/foo-build-cache:my-tag", app.RepositoryBuildCache(""))
+
+	app.Registry = "my-org"
+	assert.Equal(t, "my-org/foo-build-cache:my-tag", app.RepositoryBuildCache(""))
+
+	app.Registry = "localhost:5000/my-org"
+	assert.Equal(t, "localhost:5000/my-org/foo/build-cache:my-tag", app.RepositoryBuildCache(""))
+}
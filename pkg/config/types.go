@@ -10,14 +10,44 @@ const LatestVersion uint = 2
 type StringArray []string
 
 type App struct {
-	Name      string `yaml:"name,omitempty"`
-	Path      string `yaml:"path,omitempty"`
-	Root      string `yaml:"root,omitempty"`
-	SkipBuild bool   `yaml:"skipBuild,omitempty"`
-	Default   bool   `yaml:"default,omitempty"`
-	Platform  string `yaml:"platform,omitempty"`
-	PreBuild  string `yaml:"preBuild,omitempty"`
-	PostBuild string `yaml:"postBuild,omitempty"`
+	Name       string      `yaml:"name,omitempty"`
+	Path       string      `yaml:"path,omitempty"`
+	Root       string      `yaml:"root,omitempty"`
+	SkipBuild  bool        `yaml:"skipBuild,omitempty"`
+	Default    bool        `yaml:"default,omitempty"`
+	Platforms  StringArray `yaml:"platform,omitempty"`
+	Builder    string      `yaml:"builder,omitempty"`
+	CacheFrom  StringArray `yaml:"cacheFrom,omitempty"`
+	CacheMode  string      `yaml:"cacheMode,omitempty"`
+	Provenance bool        `yaml:"provenance,omitempty"`
+	SBOM       bool        `yaml:"sbom,omitempty"`
+	Secrets    []Secret    `yaml:"secrets,omitempty"`
+	Strict     bool        `yaml:"strict,omitempty"`
+	PreBuild   Hook        `yaml:"preBuild,omitempty"`
+	PostBuild  Hook        `yaml:"postBuild,omitempty"`
+}
+
+/*
+A command or script that runs before or after a build. Can be given as a
+single command, a list of commands, or an object with options.
+*/
+type Hook struct {
+	Run     StringArray       `yaml:"run,omitempty"`
+	Script  string            `yaml:"script,omitempty"`
+	Dir     string            `yaml:"dir,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty"`
+}
+
+/*
+A build secret. The value is read from exactly one source: an environment
+variable, a file, or the output of a shell command.
+*/
+type Secret struct {
+	ID      string `yaml:"id,omitempty"`
+	Env     string `yaml:"env,omitempty"`
+	File    string `yaml:"file,omitempty"`
+	Command string `yaml:"command,omitempty"`
 }
 
 type Target struct {
@@ -26,12 +56,70 @@ type Target struct {
 	Context   string      `yaml:"context,omitempty"`
 	Namespace string      `yaml:"namespace,omitempty"`
 	Path      string      `yaml:"path,omitempty"`
+
+	// Registry that the cluster pulls images from, if it cannot pull from the
+	// registry that images are built to. Images are copied to it on deploy.
+	Registry string `yaml:"registry,omitempty"`
+
+	RequireSignature bool `yaml:"requireSignature,omitempty"`
+}
+
+/*
+The buildx builder instance to build images with. It is created when it does
+not exist yet, so that everyone builds with the same driver.
+*/
+type BuildxBuilder struct {
+	Name       string            `yaml:"name,omitempty"`
+	Driver     string            `yaml:"driver,omitempty"`
+	Platforms  StringArray       `yaml:"platforms,omitempty"`
+	DriverOpts map[string]string `yaml:"driverOpts,omitempty"`
+}
+
+type Signing struct {
+	Key        string      `yaml:"key,omitempty"`
+	KeyEnv     string      `yaml:"keyEnv,omitempty"`
+	PublicKeys StringArray `yaml:"publicKeys,omitempty"`
+}
+
+/*
+Which images of an application to keep in the registry when pruning. Images
+deployed to a target or running in a cluster are always kept.
+*/
+type Retention struct {
+	Keep   int    `yaml:"keep,omitempty"`
+	MaxAge string `yaml:"maxAge,omitempty"`
+}
+
+/*
+How kd authenticates with a registry. Credentials are read from exactly one
+source: a Docker credential helper, a token in an environment variable, a
+service account key file, or none for anonymous access. Registries that are
+not configured use the Docker configuration file. Insecure registries are
+accessed over plain HTTP, such as a local registry for testing.
+*/
+type RegistryOptions struct {
+	Host             string `yaml:"host,omitempty"`
+	Insecure         bool   `yaml:"insecure,omitempty"`
+	CredentialHelper string `yaml:"credentialHelper,omitempty"`
+	TokenEnv         string `yaml:"tokenEnv,omitempty"`
+	Username         string `yaml:"username,omitempty"`
+	KeyFile          string `yaml:"keyFile,omitempty"`
+	Anonymous        bool   `yaml:"anonymous,omitempty"`
 }
 
 type Config struct {
-	ApiVersion uint     `yaml:"version,omitempty"`
-	Registry   string   `yaml:"registry,omitempty"`
-	Cache      string   `yaml:"cache,omitempty"`
-	Apps       []App    `yaml:"apps,omitempty"`
-	Targets    []Target `yaml:"targets,omitempty"`
+	ApiVersion uint   `yaml:"version,omitempty"`
+	Registry   string `yaml:"registry,omitempty"`
+	Cache      string `yaml:"cache,omitempty"`
+
+	DefaultBranch StringArray `yaml:"defaultBranch,omitempty"`
+
+	BuildxBuilder BuildxBuilder `yaml:"buildxBuilder,omitempty"`
+	Signing       Signing       `yaml:"signing,omitempty"`
+
+	Registries []RegistryOptions `yaml:"registries,omitempty"`
+	Retention  Retention         `yaml:"retention,omitempty"`
+
+	Apps    []App    `yaml:"apps,omitempty"`
+	Targets []Target `yaml:"targets,omitempty"`
 }
@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/voormedia/kd/pkg/config"
@@ -8,18 +9,33 @@ import (
 	"github.com/voormedia/kd/pkg/internal/gcloud"
 	"github.com/voormedia/kd/pkg/internal/kubectl"
 	"github.com/voormedia/kd/pkg/internal/kustomize"
+	"github.com/voormedia/kd/pkg/internal/signing"
 	"github.com/voormedia/kd/pkg/util"
 )
 
 func Run(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, deployClearCDNCaches bool) error {
-	var img docker.ImageManifest
+	var img docker.Image
 	if !app.SkipBuild {
-		log.Note("Retrieving image", app.Name+":"+app.Tag)
+		log.Note("Retrieving image", app.Reference())
 		image, err := docker.GetImage(log, app.Repository())
 		if err != nil {
 			return err
 		}
 		img = image
+
+		if target.RequireSignature {
+			if err := verifySignature(log, app, target, img); err != nil {
+				return err
+			}
+		}
+
+		if target.Registry != "" {
+			log.Note("Copying image to", app.TargetRepository(target))
+			_, err := docker.CopyImage(log, img, app.TargetRepository(target)+":"+target.Name)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	res, err := kustomize.GetResources(log, app, target, img.Descriptor.Digest.String())
@@ -77,3 +93,27 @@ func Run(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarge
 	}
 	return nil
 }
+
+func verifySignature(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, img docker.Image) error {
+	keys, err := signing.ReadPublicKeys(app.Signing.PublicKeys)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := docker.GetSignatures(log, img)
+	if err != nil {
+		return err
+	}
+
+	dgst := img.Descriptor.Digest.String()
+	if len(signatures) == 0 {
+		return fmt.Errorf("Image %s@%s is not signed, but target '%s' requires a signature", app.Name, dgst, target.Name)
+	}
+
+	if !signing.Verify(keys, dgst, signatures) {
+		return fmt.Errorf("Image %s@%s has no valid signature from any of the configured public keys", app.Name, dgst)
+	}
+
+	log.Note("Verified signature of", app.Name+"@"+dgst)
+	return nil
+}
@@ -0,0 +1,190 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/kubectl"
+	"github.com/voormedia/kd/pkg/internal/kustomize"
+	"github.com/voormedia/kd/pkg/util"
+	"golang.org/x/term"
+	core "k8s.io/api/core/v1"
+)
+
+// Starts bash if the image has it, and sh otherwise.
+var shellCommand = []string{"/bin/sh", "-c", "if command -v bash >/dev/null; then exec bash; else exec sh; fi"}
+
+// Annotation that names the container kubectl uses by default.
+const annotationDefaultContainer = "kubectl.kubernetes.io/default-container"
+
+type Options struct {
+	// Name of the pod to use. By default a ready pod is picked.
+	Pod string
+
+	// Let the user choose one of the ready pods.
+	Choose bool
+
+	// Name of the container to use. By default the container that runs the
+	// image of the app is used.
+	Container string
+}
+
+// Executes a command in a ready pod of the app in the target. A TTY is
+// allocated if kd runs in a terminal. If the command fails, the error is an
+// *exec.ExitError with its exit code.
+func Run(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, options Options, command []string) error {
+	pod, err := selectPod(log, app, target, options)
+	if err != nil {
+		return err
+	}
+
+	container := options.Container
+	if container == "" {
+		container = appContainer(app, target, pod)
+	}
+
+	tty := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+
+	log.Note("Executing in", pod.Name+"/"+container)
+	return kubectl.Exec(log, target, pod.Name, container, tty, command...)
+}
+
+// Starts an interactive shell in a ready pod of the app in the target.
+func Shell(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, options Options) error {
+	return Run(log, app, target, options, shellCommand)
+}
+
+func selectPod(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, options Options) (core.Pod, error) {
+	workloads, err := kustomize.GetWorkloads(log, app, target)
+	if err != nil {
+		return core.Pod{}, err
+	}
+
+	found, err := kubectl.GetPods(log, target, kustomize.CommonSelector(workloads))
+	if err != nil {
+		return core.Pod{}, err
+	}
+
+	pods := workloadPods(workloads, found)
+
+	if options.Pod != "" {
+		for _, pod := range pods {
+			if pod.Name == options.Pod {
+				return pod, nil
+			}
+		}
+		return core.Pod{}, fmt.Errorf("Pod '%s' does not belong to '%s' in '%s'", options.Pod, app.Name, target.Name)
+	}
+
+	ready := readyPods(pods)
+	if len(ready) == 0 {
+		return core.Pod{}, fmt.Errorf("No ready pods of '%s' found in '%s'", app.Name, target.Name)
+	}
+
+	if !options.Choose || len(ready) == 1 {
+		return ready[0], nil
+	}
+
+	labels := make([]string, len(ready))
+	for i, pod := range ready {
+		labels[i] = describePod(pod, time.Now())
+	}
+
+	var choice int
+	err = survey.AskOne(&survey.Select{
+		Message: "Select pod:",
+		Options: labels,
+	}, &choice, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		return core.Pod{}, err
+	}
+
+	return ready[choice], nil
+}
+
+// Returns the pods that belong to any of the workloads.
+func workloadPods(workloads []kustomize.Workload, pods []core.Pod) []core.Pod {
+	var matching []core.Pod
+	for _, pod := range pods {
+		for _, workload := range workloads {
+			if workload.Matches(pod.Labels) {
+				matching = append(matching, pod)
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// Returns the pods that are ready to serve, most recently started first.
+func readyPods(pods []core.Pod) []core.Pod {
+	var ready []core.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != core.PodRunning {
+			continue
+		}
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == core.PodReady && condition.Status == core.ConditionTrue {
+				ready = append(ready, pod)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(ready, func(i, j int) bool {
+		return startTime(ready[i]).After(startTime(ready[j]))
+	})
+
+	return ready
+}
+
+func startTime(pod core.Pod) time.Time {
+	if pod.Status.StartTime == nil {
+		return time.Time{}
+	}
+	return pod.Status.StartTime.Time
+}
+
+func describePod(pod core.Pod, now time.Time) string {
+	restarts := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+
+	desc := pod.Name
+	if start := startTime(pod); !start.IsZero() {
+		desc += " (started " + util.FormatAge(now.Sub(start)) + " ago"
+		if restarts > 0 {
+			desc += fmt.Sprintf(", %d restarts", restarts)
+		}
+		desc += ")"
+	}
+	return desc
+}
+
+// Returns the container of a pod that runs the image of the app. Otherwise the
+// default container of the pod is returned, or its first container.
+func appContainer(app *config.ResolvedApp, target *config.ResolvedTarget, pod core.Pod) string {
+	repository := app.TargetRepository(target)
+	for _, container := range pod.Spec.Containers {
+		rest, ok := strings.CutPrefix(container.Image, repository)
+		if ok && (rest == "" || strings.HasPrefix(rest, "@") || strings.HasPrefix(rest, ":")) {
+			return container.Name
+		}
+	}
+
+	if name := pod.Annotations[annotationDefaultContainer]; name != "" {
+		return name
+	}
+
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
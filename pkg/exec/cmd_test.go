@@ -0,0 +1,86 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/kustomize"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func pod(name string, started time.Time, ready bool) core.Pod {
+	status := core.ConditionFalse
+	if ready {
+		status = core.ConditionTrue
+	}
+
+	return core.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: name},
+		Status: core.PodStatus{
+			Phase:      core.PodRunning,
+			StartTime:  &meta.Time{Time: started},
+			Conditions: []core.PodCondition{{Type: core.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestReadyPods(t *testing.T) {
+	now := time.Now()
+	terminating := pod("web-4", now, true)
+	terminating.DeletionTimestamp = &meta.Time{Time: now}
+
+	pods := readyPods([]core.Pod{
+		pod("web-1", now.Add(-2*time.Hour), true),
+		pod("web-2", now.Add(-time.Hour), true),
+		pod("web-3", now, false),
+		terminating,
+	})
+
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+
+	assert.Equal(t, []string{"web-2", "web-1"}, names)
+}
+
+func TestWorkloadPods(t *testing.T) {
+	web := pod("web-1", time.Now(), true)
+	web.Labels = map[string]string{"app": "shop", "component": "web"}
+	worker := pod("worker-1", time.Now(), true)
+	worker.Labels = map[string]string{"app": "shop", "component": "worker"}
+	cron := pod("cron-1", time.Now(), true)
+	cron.Labels = map[string]string{"app": "shop", "component": "cron"}
+
+	pods := workloadPods([]kustomize.Workload{
+		{Kind: "Deployment", Name: "web", Selector: map[string]string{"app": "shop", "component": "web"}},
+		{Kind: "Deployment", Name: "worker", Selector: map[string]string{"app": "shop", "component": "worker"}},
+	}, []core.Pod{web, worker, cron})
+
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+
+	assert.Equal(t, []string{"web-1", "worker-1"}, names)
+}
+
+func TestAppContainer(t *testing.T) {
+	app := &config.ResolvedApp{App: config.App{Name: "web"}, Registry: "eu.gcr.io/project"}
+	target := &config.ResolvedTarget{Target: config.Target{Name: "production"}}
+
+	p := core.Pod{Spec: core.PodSpec{Containers: []core.Container{
+		{Name: "proxy", Image: "eu.gcr.io/project/web-proxy@sha256:1"},
+		{Name: "app", Image: "eu.gcr.io/project/web@sha256:2"},
+	}}}
+	assert.Equal(t, "app", appContainer(app, target, p))
+
+	p.Spec.Containers[1].Image = "nginx"
+	assert.Equal(t, "proxy", appContainer(app, target, p))
+
+	p.Annotations = map[string]string{annotationDefaultContainer: "app"}
+	assert.Equal(t, "app", appContainer(app, target, p))
+}
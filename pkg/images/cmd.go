@@ -0,0 +1,52 @@
+package images
+
+import (
+	"sort"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// An image of an application in the registry, with the targets it is
+// currently deployed to.
+type Image struct {
+	docker.ImageDetails
+	Targets []string `json:"targets"`
+}
+
+// Lists the images of the app in the registry. Images that are tagged with
+// the name of a target come first, followed by all others, most recently
+// created first.
+func List(log *util.Logger, app *config.ResolvedApp, targets []string) ([]Image, error) {
+	log.Note("Retrieving images of", app.Name)
+	details, err := docker.ListImages(log, app.Registry+"/"+app.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return group(details, targets), nil
+}
+
+func group(details []docker.ImageDetails, targets []string) []Image {
+	isTarget := map[string]bool{}
+	for _, target := range targets {
+		isTarget[target] = true
+	}
+
+	images := make([]Image, len(details))
+	for i, detail := range details {
+		images[i] = Image{ImageDetails: detail, Targets: []string{}}
+		for _, tag := range detail.Tags {
+			if isTarget[tag] {
+				images[i].Targets = append(images[i].Targets, tag)
+			}
+		}
+	}
+
+	sort.SliceStable(images, func(i, j int) bool {
+		return len(images[i].Targets) > 0 && len(images[j].Targets) == 0
+	})
+
+	return images
+}
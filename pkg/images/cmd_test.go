@@ -0,0 +1,28 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+func TestGroup(t *testing.T) {
+	details := []docker.ImageDetails{
+		{Digest: "sha256:3", Tags: []string{"latest", "c3"}},
+		{Digest: "sha256:2", Tags: []string{"acceptance", "b2"}},
+		{Digest: "sha256:1", Tags: []string{"a1"}},
+		{Digest: "sha256:0", Tags: []string{"production", "z0"}},
+	}
+
+	images := group(details, []string{"acceptance", "production"})
+
+	var digests []string
+	for _, image := range images {
+		digests = append(digests, image.Digest)
+	}
+
+	assert.Equal(t, []string{"sha256:2", "sha256:0", "sha256:3", "sha256:1"}, digests)
+	assert.Equal(t, []string{"acceptance"}, images[0].Targets)
+	assert.Equal(t, []string{}, images[2].Targets)
+}
@@ -0,0 +1,193 @@
+package images
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/internal/kubectl"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+type Policy struct {
+	// Number of most recently created images to keep.
+	Keep int
+
+	// Images younger than this are kept. Zero means images are kept regardless
+	// of their age.
+	MaxAge time.Duration
+}
+
+// Returns the retention policy of the app from kdeploy.conf.
+func ConfiguredPolicy(app *config.ResolvedApp) (Policy, error) {
+	policy := Policy{Keep: app.Retention.Keep}
+	if app.Retention.MaxAge != "" {
+		age, err := util.ParseAge(app.Retention.MaxAge)
+		if err != nil {
+			return policy, err
+		}
+		policy.MaxAge = age
+	}
+	return policy, nil
+}
+
+type Entry struct {
+	docker.ImageDetails
+	Keep    bool   `json:"keep"`
+	Reason  string `json:"reason"`
+	Deleted bool   `json:"deleted"`
+}
+
+// Determines which images of the app to keep and which to delete. Images that
+// are tagged "latest", deployed to a target or used by pods of a target are
+// always kept, including pods that pull from the registry of their target. Of the others, images are kept if they are among the most
+// recent ones or younger than the maximum age of the policy.
+func Plan(log *util.Logger, app *config.ResolvedApp, targets []*config.ResolvedTarget, policy Policy) ([]Entry, error) {
+	if policy.Keep == 0 && policy.MaxAge == 0 {
+		return nil, fmt.Errorf("No retention policy configured, add 'retention' to kdeploy.conf or use --keep or --max-age")
+	}
+
+	running := map[string]string{}
+	for _, target := range targets {
+		log.Note("Retrieving images of pods in", target.Name)
+		refs, err := kubectl.GetPodImages(log, target)
+		if err != nil {
+			return nil, err
+		}
+
+		addRunning(running, app, target, refs)
+	}
+
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
+	}
+
+	log.Note("Retrieving images of", app.Name)
+	details, err := docker.ListImages(log, app.Registry+"/"+app.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan(details, names, running, policy, time.Now()), nil
+}
+
+func plan(details []docker.ImageDetails, targets []string, running map[string]string, policy Policy, now time.Time) []Entry {
+	// Images are listed most recently created first.
+	recent := map[string]int{}
+	for i, image := range details {
+		recent[image.Digest] = i
+	}
+
+	entries := make([]Entry, len(details))
+	for i, image := range group(details, targets) {
+		entry := Entry{ImageDetails: image.ImageDetails, Keep: true}
+		age := now.Sub(image.CreatedAt())
+
+		switch {
+		case len(image.Targets) > 0:
+			entry.Reason = "deployed to " + strings.Join(image.Targets, ", ")
+		case running[image.Digest] != "":
+			entry.Reason = "running in " + running[image.Digest]
+		case runningTag(image.Tags, running) != "":
+			entry.Reason = "running in " + runningTag(image.Tags, running)
+		case slices.Contains(image.Tags, config.DefaultTag):
+			entry.Reason = "tagged " + config.DefaultTag
+		case recent[image.Digest] < policy.Keep:
+			entry.Reason = fmt.Sprintf("one of %d most recent", policy.Keep)
+		case policy.MaxAge > 0 && image.Created == nil:
+			entry.Reason = "unknown age"
+		case policy.MaxAge > 0 && age < policy.MaxAge:
+			entry.Reason = "younger than " + util.FormatAge(policy.MaxAge)
+		default:
+			entry.Keep = false
+			if policy.MaxAge > 0 {
+				entry.Reason = "older than " + util.FormatAge(policy.MaxAge)
+			} else {
+				entry.Reason = fmt.Sprintf("not one of %d most recent", policy.Keep)
+			}
+		}
+
+		entries[i] = entry
+	}
+
+	return entries
+}
+
+// Deletes the images in the plan that are not kept from the registry.
+func Prune(log *util.Logger, app *config.ResolvedApp, plan []Entry) error {
+	for i := range plan {
+		entry := &plan[i]
+		if entry.Keep {
+			continue
+		}
+
+		log.Note("Deleting", app.Name+"@"+entry.Digest)
+		if err := docker.DeleteImage(log, app.Registry+"/"+app.Name, entry.ImageDetails); err != nil {
+			return err
+		}
+		entry.Deleted = true
+	}
+
+	return nil
+}
+
+// Records the digests and tags of the images of the app that pods of the
+// target run. Targets with their own registry pull copies of the images of the
+// app, which have the same digests.
+func addRunning(running map[string]string, app *config.ResolvedApp, target *config.ResolvedTarget, refs []string) {
+	repositories := []string{app.Registry + "/" + app.Name}
+	if repository := app.TargetRepository(target); !slices.Contains(repositories, repository) {
+		repositories = append(repositories, repository)
+	}
+
+	for _, ref := range refs {
+		for _, repository := range repositories {
+			if id := imageID(repository, ref); id != "" && running[id] == "" {
+				running[id] = target.Name
+			}
+		}
+	}
+}
+
+// Returns the digest or tag of an image reference of a pod if it refers to
+// the given repository. The container runtime may prefix resolved references
+// with a scheme, such as "docker-pullable://".
+func imageID(repository string, ref string) string {
+	if _, rest, ok := strings.Cut(ref, "://"); ok {
+		ref = rest
+	}
+
+	rest, ok := strings.CutPrefix(ref, repository)
+	if !ok {
+		return ""
+	}
+
+	switch {
+	case rest == "":
+		return config.DefaultTag
+	case strings.HasPrefix(rest, "@"):
+		return rest[1:]
+	case strings.HasPrefix(rest, ":"):
+		// A tag, possibly followed by a digest.
+		tag, dgst, ok := strings.Cut(rest[1:], "@")
+		if ok {
+			return dgst
+		}
+		return tag
+	default:
+		return ""
+	}
+}
+
+func runningTag(tags []string, running map[string]string) string {
+	for _, tag := range tags {
+		if running[tag] != "" {
+			return running[tag]
+		}
+	}
+	return ""
+}
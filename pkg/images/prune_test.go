@@ -0,0 +1,74 @@
+package images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	details := []docker.ImageDetails{
+		{Digest: "sha256:6", Tags: []string{"latest", "f6"}, Created: at(now.Add(-1 * day))},
+		{Digest: "sha256:5", Tags: []string{"e5"}, Created: at(now.Add(-2 * day))},
+		{Digest: "sha256:4", Tags: []string{"acceptance", "d4"}, Created: at(now.Add(-40 * day))},
+		{Digest: "sha256:3", Tags: []string{"c3"}, Created: at(now.Add(-50 * day))},
+		{Digest: "sha256:2", Tags: []string{"b2"}, Created: at(now.Add(-60 * day))},
+		{Digest: "sha256:1", Tags: []string{"a1"}, Created: at(now.Add(-70 * day))},
+		{Digest: "sha256:0", Tags: []string{"z0"}},
+	}
+
+	running := map[string]string{"sha256:2": "production"}
+	policy := Policy{Keep: 3, MaxAge: 30 * day}
+
+	reasons := map[string]string{}
+	for _, entry := range plan(details, []string{"acceptance", "production"}, running, policy, now) {
+		if !entry.Keep {
+			reasons[entry.Digest] = "delete: " + entry.Reason
+		} else {
+			reasons[entry.Digest] = entry.Reason
+		}
+	}
+
+	assert.Equal(t, map[string]string{
+		"sha256:6": "tagged latest",
+		"sha256:5": "one of 3 most recent",
+		"sha256:4": "deployed to acceptance",
+		"sha256:3": "delete: older than 30d",
+		"sha256:2": "running in production",
+		"sha256:1": "delete: older than 30d",
+		"sha256:0": "unknown age",
+	}, reasons)
+}
+
+func TestImageID(t *testing.T) {
+	repo := "eu.gcr.io/project/foo"
+
+	assert.Equal(t, "sha256:abc", imageID(repo, "docker-pullable://eu.gcr.io/project/foo@sha256:abc"))
+	assert.Equal(t, "sha256:abc", imageID(repo, "eu.gcr.io/project/foo:production@sha256:abc"))
+	assert.Equal(t, "production", imageID(repo, "eu.gcr.io/project/foo:production"))
+	assert.Equal(t, "latest", imageID(repo, "eu.gcr.io/project/foo"))
+	assert.Equal(t, "", imageID(repo, "eu.gcr.io/project/foo-worker:production"))
+	assert.Equal(t, "", imageID(repo, "nginx:latest"))
+}
+
+func TestAddRunningWithTargetRegistry(t *testing.T) {
+	app := &config.ResolvedApp{App: config.App{Name: "foo"}, Registry: "eu.gcr.io/project"}
+	mirror := &config.ResolvedTarget{Target: config.Target{Name: "china", Registry: "registry.cn/project"}}
+	production := &config.ResolvedTarget{Target: config.Target{Name: "production"}}
+
+	running := map[string]string{}
+	addRunning(running, app, mirror, []string{"registry.cn/project/foo@sha256:1", "eu.gcr.io/project/foo@sha256:2"})
+	addRunning(running, app, production, []string{"registry.cn/project/foo@sha256:3"})
+
+	assert.Equal(t, map[string]string{"sha256:1": "china", "sha256:2": "china"}, running)
+}
@@ -0,0 +1,151 @@
+package inspect
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+const (
+	predicateSPDX         = "https://spdx.dev/Document"
+	predicateProvenance02 = "https://slsa.dev/provenance/v0.2"
+	predicateProvenance1  = "https://slsa.dev/provenance/v1"
+	buildkitMetadata      = "https://mobyproject.org/buildkit@v1#metadata"
+)
+
+// Summary of the attestations of a single platform image.
+type Summary struct {
+	Platform   string   `json:"platform"`
+	Digest     string   `json:"digest"`
+	Revision   string   `json:"revision,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	Provenance bool     `json:"provenance"`
+	SBOM       bool     `json:"sbom"`
+	BaseImages []string `json:"baseImages,omitempty"`
+	Packages   int      `json:"packages,omitempty"`
+}
+
+func Run(log *util.Logger, app *config.ResolvedApp) (docker.Image, []Summary, error) {
+	log.Note("Retrieving image", app.Reference())
+	img, err := docker.GetImage(log, app.Repository())
+	if err != nil {
+		return img, nil, err
+	}
+
+	images, err := docker.InspectImage(log, img)
+	if err != nil {
+		return img, nil, err
+	}
+
+	summaries := make([]Summary, len(images))
+	for i, image := range images {
+		summaries[i] = summarize(image)
+	}
+
+	return img, summaries, nil
+}
+
+type material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type vcsMetadata struct {
+	Metadata map[string]struct {
+		VCS struct {
+			Revision string `json:"revision"`
+			Source   string `json:"source"`
+		} `json:"vcs"`
+	} `json:"metadata"`
+}
+
+func summarize(image docker.PlatformImage) Summary {
+	summary := Summary{
+		Platform: image.Platform,
+		Digest:   image.Digest,
+		Revision: image.Labels["org.opencontainers.image.revision"],
+		Source:   image.Labels["org.opencontainers.image.source"],
+	}
+
+	for _, statement := range image.Statements {
+		var materials []material
+		var metadata vcsMetadata
+
+		switch statement.PredicateType {
+		case predicateSPDX:
+			var sbom struct {
+				Packages []json.RawMessage `json:"packages"`
+			}
+			json.Unmarshal(statement.Predicate, &sbom)
+			summary.SBOM = true
+			summary.Packages = len(sbom.Packages)
+			continue
+
+		case predicateProvenance02:
+			var provenance struct {
+				vcsMetadata
+				Materials []material `json:"materials"`
+			}
+			json.Unmarshal(statement.Predicate, &provenance)
+			materials, metadata = provenance.Materials, provenance.vcsMetadata
+
+		case predicateProvenance1:
+			var provenance struct {
+				BuildDefinition struct {
+					ResolvedDependencies []material `json:"resolvedDependencies"`
+				} `json:"buildDefinition"`
+				RunDetails vcsMetadata `json:"runDetails"`
+			}
+			json.Unmarshal(statement.Predicate, &provenance)
+			materials, metadata = provenance.BuildDefinition.ResolvedDependencies, provenance.RunDetails
+
+		default:
+			continue
+		}
+
+		summary.Provenance = true
+		for _, m := range materials {
+			if image := baseImage(m); image != "" {
+				summary.BaseImages = append(summary.BaseImages, image)
+			}
+		}
+
+		if vcs := metadata.Metadata[buildkitMetadata].VCS; vcs.Revision != "" {
+			summary.Revision = vcs.Revision
+			summary.Source = vcs.Source
+		}
+	}
+
+	return summary
+}
+
+// Converts a material such as pkg:docker/ruby@3.3-slim?platform=linux%2Famd64
+// to an image reference.
+func baseImage(m material) string {
+	purl, ok := strings.CutPrefix(m.URI, "pkg:docker/")
+	if !ok {
+		return ""
+	}
+
+	purl, _, _ = strings.Cut(purl, "?")
+	name, version, _ := strings.Cut(purl, "@")
+
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+
+	image := name
+	if version != "" && !strings.Contains(version, ":") {
+		image += ":" + version
+	}
+
+	if sha, ok := m.Digest["sha256"]; ok {
+		image += "@sha256:" + sha
+	}
+
+	return image
+}
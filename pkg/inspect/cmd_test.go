@@ -0,0 +1,63 @@
+package inspect
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+func TestSummarize(t *testing.T) {
+	image := docker.PlatformImage{
+		Platform: "linux/amd64",
+		Digest:   "sha256:0123",
+		Labels: map[string]string{
+			"org.opencontainers.image.revision": "abc",
+		},
+		Statements: []docker.Statement{{
+			PredicateType: "https://spdx.dev/Document",
+			Predicate:     json.RawMessage(`{"packages": [{"name": "a"}, {"name": "b"}]}`),
+		}, {
+			PredicateType: "https://slsa.dev/provenance/v0.2",
+			Predicate: json.RawMessage(`{
+				"materials": [
+					{"uri": "pkg:docker/ruby@3.3-slim?platform=linux%2Famd64", "digest": {"sha256": "4567"}},
+					{"uri": "https://github.com/voormedia/kd.git"}
+				],
+				"metadata": {
+					"https://mobyproject.org/buildkit@v1#metadata": {
+						"vcs": {"revision": "def", "source": "https://github.com/voormedia/kd"}
+					}
+				}
+			}`),
+		}},
+	}
+
+	assert.Equal(t, Summary{
+		Platform:   "linux/amd64",
+		Digest:     "sha256:0123",
+		Revision:   "def",
+		Source:     "https://github.com/voormedia/kd",
+		Provenance: true,
+		SBOM:       true,
+		BaseImages: []string{"ruby:3.3-slim@sha256:4567"},
+		Packages:   2,
+	}, summarize(image))
+}
+
+func TestSummarizeWithoutAttestations(t *testing.T) {
+	image := docker.PlatformImage{
+		Platform: "linux/amd64",
+		Digest:   "sha256:0123",
+		Labels: map[string]string{
+			"org.opencontainers.image.revision": "abc",
+		},
+	}
+
+	assert.Equal(t, Summary{
+		Platform: "linux/amd64",
+		Digest:   "sha256:0123",
+		Revision: "abc",
+	}, summarize(image))
+}
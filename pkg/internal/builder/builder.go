@@ -0,0 +1,182 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Spec describes a single image build, independently of the tool that
+// executes it.
+type Spec struct {
+	Dockerfile string
+	Context    string
+	Platforms  []string
+	Tags       []string
+	Labels     map[string]string
+	Secrets    []Secret
+	SSH        bool
+
+	// Attach SLSA provenance and SPDX SBOM attestations to the image.
+	Provenance bool
+	SBOM       bool
+
+	// Registry references to import build cache from, in order of preference.
+	CacheFrom []string
+
+	// Registry reference to export build cache to. Empty if no cache should
+	// be written.
+	CacheTo string
+
+	// Either "max" to export cache for all build stages, or "min" to export
+	// cache for the final image only.
+	CacheMode string
+
+	// Hosts of registries that are accessed over plain HTTP.
+	InsecureRegistries []string
+
+	Output Output
+}
+
+// A build secret that is read from either an environment variable or a file.
+// If a value is given, it is set in the environment variable of the builder
+// process only.
+type Secret struct {
+	ID    string
+	Env   string
+	File  string
+	Value string
+}
+
+// Returns the secret in the format of the --secret option of docker buildx,
+// which is also accepted by Podman and Buildah.
+func (s Secret) String() string {
+	if s.File != "" {
+		return "id=" + s.ID + ",src=" + s.File
+	}
+	return "id=" + s.ID + ",env=" + s.Env
+}
+
+// Returns the environment variables that pass secret values to the builder.
+func (spec *Spec) secretEnv() []string {
+	var env []string
+	for _, secret := range spec.Secrets {
+		if secret.Env != "" && secret.Value != "" {
+			env = append(env, secret.Env+"="+secret.Value)
+		}
+	}
+	return env
+}
+
+// Output describes where the result of a build is written to. The zero value
+// pushes the image to the registry.
+type Output struct {
+	Local  bool
+	File   string
+	Format string
+}
+
+type Builder interface {
+	Name() string
+
+	// Reports whether build cache can be exported to a registry.
+	SupportsCacheExport(log *util.Logger) bool
+
+	// Builds the image, and returns the digest of the image manifest or index,
+	// if the builder reports it.
+	Build(log *util.Logger, spec *Spec) (string, error)
+}
+
+const Default = "buildx"
+
+var Names = []string{"buildx", "podman", "buildah", "kaniko"}
+
+// Returns the builder with the given name. Images are built with buildx by
+// default. The buildx builder instance is only used by buildx.
+func New(name string, instance string) (Builder, error) {
+	switch name {
+	case "", "buildx":
+		return &buildx{instance: instance}, nil
+	case "podman", "buildah":
+		return &containers{bin: name}, nil
+	case "kaniko":
+		return &kaniko{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown builder '%s', must be one of %s", name, strings.Join(Names, ", "))
+	}
+}
+
+type UnsupportedError struct {
+	Builder string
+	Feature string
+}
+
+func (err *UnsupportedError) Error() string {
+	return fmt.Sprintf("The %s builder does not support %s", err.Builder, err.Feature)
+}
+
+func unsupported(b Builder, feature string) error {
+	return &UnsupportedError{Builder: b.Name(), Feature: feature}
+}
+
+func sortedLabels(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return pairs
+}
+
+// Strips the tag from a registry reference. Podman, Buildah and Kaniko store
+// cache layers in a repository instead of under a single tag.
+func repositoryOf(ref string) string {
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref, ":"); colon > slash {
+		return ref[:colon]
+	}
+	return ref
+}
+
+// Reports whether the registry of an image reference is accessed over plain
+// HTTP.
+func (spec *Spec) isInsecure(ref string) bool {
+	host, _, _ := strings.Cut(ref, "/")
+	return slices.Contains(spec.InsecureRegistries, host)
+}
+
+// Creates an empty temporary file for a builder to write the image digest or
+// other build metadata to.
+func tempFile(pattern string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	return file.Name(), nil
+}
+
+// Reads a digest that was written to a file by a builder.
+func readDigest(path string) string {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+func cacheMode(spec *Spec) string {
+	if spec.CacheMode == "" {
+		return "max"
+	}
+	return spec.CacheMode
+}
@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range Names {
+		b, err := New(name, "")
+		assert.Nil(t, err)
+		assert.Equal(t, name, b.Name())
+	}
+
+	b, err := New("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, Default, b.Name())
+
+	_, err = New("docker", "")
+	assert.Equal(t, "Unknown builder 'docker', must be one of buildx, podman, buildah, kaniko", err.Error())
+}
+
+func TestRepositoryOf(t *testing.T) {
+	assert.Equal(t, "eu.gcr.io/project/app/build-cache", repositoryOf("eu.gcr.io/project/app/build-cache:main-ssh"))
+	assert.Equal(t, "localhost:5000/app", repositoryOf("localhost:5000/app:main"))
+	assert.Equal(t, "localhost:5000/app", repositoryOf("localhost:5000/app"))
+}
+
+func TestBuildxOutputInsecureRegistry(t *testing.T) {
+	b := &buildx{}
+	spec := &Spec{Tags: []string{"localhost:5000/app:latest"}}
+	assert.Equal(t, `type=image,"name=localhost:5000/app:latest",push=true`, b.output(spec))
+
+	spec.InsecureRegistries = []string{"localhost:5000"}
+	assert.Equal(t, `type=image,"name=localhost:5000/app:latest",push=true,registry.insecure=true`, b.output(spec))
+}
+
+func TestKanikoUnsupportedSecrets(t *testing.T) {
+	b, _ := New("kaniko", "")
+	_, err := b.Build(nil, &Spec{Secrets: []Secret{{ID: "npm", Env: "NPM_TOKEN"}}})
+	assert.Equal(t, "The kaniko builder does not support build secrets", err.Error())
+}
+
+func TestSecretEnv(t *testing.T) {
+	spec := &Spec{Secrets: []Secret{
+		{ID: "npm", Env: "NPM_TOKEN"},
+		{ID: "gem", Env: "KD_SECRET_GEM", Value: "token"},
+		{ID: "ssh", File: "/home/me/.ssh/id_rsa"},
+	}}
+	assert.Equal(t, []string{"KD_SECRET_GEM=token"}, spec.secretEnv())
+}
+
+func TestParseInspect(t *testing.T) {
+	status := parseInspect(`Name:          kd
+Driver:        docker-container
+Last Activity: 2024-11-05 10:12:44 +0000 UTC
+
+Nodes:
+Name:                  kd0
+Endpoint:              unix:///var/run/docker.sock
+Status:                running
+BuildKit version:      v0.16.0
+Platforms:             linux/amd64, linux/amd64/v2, linux/arm64*
+`)
+
+	assert.Equal(t, &InstanceStatus{
+		Name:        "kd",
+		Driver:      "docker-container",
+		Status:      "running",
+		Platforms:   []string{"linux/amd64", "linux/amd64/v2", "linux/arm64"},
+		CacheExport: true,
+	}, status)
+}
+
+func TestParseInspectDockerDriver(t *testing.T) {
+	status := parseInspect("Name:   default\nDriver: docker\n\nNodes:\nName: default\nStatus: running\n")
+	assert.False(t, status.CacheExport)
+}
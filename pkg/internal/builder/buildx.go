@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Builds images with docker buildx, using the named builder instance, or the
+// currently selected builder if no name is given.
+type buildx struct {
+	instance string
+}
+
+func (b *buildx) Name() string {
+	return "buildx"
+}
+
+func (b *buildx) SupportsCacheExport(log *util.Logger) bool {
+	status, err := InspectInstance(log, b.instance)
+	if err != nil {
+		log.Debug(err)
+		return false
+	}
+
+	return status.CacheExport
+}
+
+func (b *buildx) Build(log *util.Logger, spec *Spec) (string, error) {
+	metadata, err := tempFile("kd-metadata-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(metadata)
+
+	cmd := []string{
+		"buildx", "build",
+		"--metadata-file", metadata,
+	}
+
+	if b.instance != "" {
+		cmd = append(cmd, "--builder", b.instance)
+	}
+
+	if spec.SSH {
+		cmd = append(cmd, "--ssh", "default")
+	}
+
+	for _, secret := range spec.Secrets {
+		cmd = append(cmd, "--secret", secret.String())
+	}
+
+	if spec.Provenance {
+		cmd = append(cmd, "--provenance=mode=max")
+	} else if spec.CacheTo != "" {
+		cmd = append(cmd, "--provenance=false")
+	}
+
+	if spec.SBOM {
+		cmd = append(cmd, "--sbom=true")
+	}
+
+	if spec.CacheTo != "" {
+		cmd = append(cmd, "--cache-to", "type=registry,ref="+spec.CacheTo+",mode="+cacheMode(spec)+insecureAttr(spec, spec.CacheTo))
+	}
+
+	for _, ref := range spec.CacheFrom {
+		cmd = append(cmd, "--cache-from", "type=registry,ref="+ref+insecureAttr(spec, ref))
+	}
+
+	cmd = append(cmd,
+		"--output="+b.output(spec),
+		"--file", spec.Dockerfile,
+		"--platform", strings.Join(spec.Platforms, ","),
+	)
+
+	for _, tag := range spec.Tags {
+		cmd = append(cmd, "--tag", tag)
+	}
+
+	for _, label := range sortedLabels(spec.Labels) {
+		cmd = append(cmd, "--label", label)
+	}
+
+	if err := util.RunWithEnv(log, spec.secretEnv(), "docker", append(cmd, spec.Context)...); err != nil {
+		return "", err
+	}
+
+	return readMetadataDigest(metadata), nil
+}
+
+// Returns the attribute that lets BuildKit access a registry over plain HTTP,
+// if needed for the reference.
+func insecureAttr(spec *Spec, ref string) string {
+	if spec.isInsecure(ref) {
+		return ",registry.insecure=true"
+	}
+	return ""
+}
+
+// Reads the image digest from a metadata file that is written by buildx.
+func readMetadataDigest(path string) string {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var metadata struct {
+		Digest string `json:"containerimage.digest"`
+	}
+
+	json.Unmarshal(bytes, &metadata)
+	return metadata.Digest
+}
+
+func (b *buildx) output(spec *Spec) string {
+	name := "\"name=" + strings.Join(spec.Tags, ",") + "\""
+
+	if !spec.Output.Local {
+		return "type=image," + name + ",push=true" + insecureAttr(spec, spec.Tags[0])
+	}
+
+	format := spec.Output.Format
+	if format == "" {
+		format = "docker"
+	}
+
+	if spec.Output.File != "" {
+		return "type=" + format + "," + name + ",dest=" + spec.Output.File
+	}
+
+	return "type=docker," + name
+}
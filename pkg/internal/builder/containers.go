@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Builds images with Podman or Buildah, which do not require a Docker daemon
+// and accept the same build flags.
+type containers struct {
+	bin string
+}
+
+func (b *containers) Name() string {
+	return b.bin
+}
+
+func (b *containers) SupportsCacheExport(log *util.Logger) bool {
+	return true
+}
+
+func (b *containers) Build(log *util.Logger, spec *Spec) (string, error) {
+	if spec.Provenance || spec.SBOM {
+		return "", unsupported(b, "provenance and SBOM attestations")
+	}
+
+	multiPlatform := len(spec.Platforms) > 1
+	if multiPlatform && spec.Output.File != "" {
+		return "", unsupported(b, "exporting multi-platform images to a file")
+	}
+
+	cmd := []string{
+		"build",
+		"--layers",
+	}
+
+	if spec.SSH {
+		cmd = append(cmd, "--ssh", "default")
+	}
+
+	for _, secret := range spec.Secrets {
+		cmd = append(cmd, "--secret", secret.String())
+	}
+
+	if spec.CacheTo != "" {
+		if cacheMode(spec) != "max" {
+			log.Warn("The", b.Name(), "builder does not support cache mode", spec.CacheMode+", exporting cache for all layers")
+		}
+
+		cmd = append(cmd, "--cache-to", repositoryOf(spec.CacheTo))
+	}
+
+	for _, ref := range spec.CacheFrom {
+		cmd = append(cmd, "--cache-from", repositoryOf(ref))
+	}
+
+	if spec.isInsecure(spec.CacheTo) || slices.ContainsFunc(spec.CacheFrom, spec.isInsecure) {
+		cmd = append(cmd, "--tls-verify=false")
+	}
+
+	cmd = append(cmd,
+		"--file", spec.Dockerfile,
+		"--platform", strings.Join(spec.Platforms, ","),
+	)
+
+	if multiPlatform {
+		// Images for multiple platforms are collected in a manifest list,
+		// which would otherwise keep the images of earlier builds.
+		util.CaptureWithoutStdErr(log, b.bin, "manifest", "rm", spec.Tags[0])
+		cmd = append(cmd, "--manifest", spec.Tags[0])
+	} else {
+		for _, tag := range spec.Tags {
+			cmd = append(cmd, "--tag", tag)
+		}
+	}
+
+	for _, label := range sortedLabels(spec.Labels) {
+		cmd = append(cmd, "--label", label)
+	}
+
+	if err := util.RunWithEnv(log, spec.secretEnv(), b.bin, append(cmd, spec.Context)...); err != nil {
+		return "", err
+	}
+
+	digestFile, err := tempFile("kd-digest-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(digestFile)
+
+	if spec.Output.File != "" {
+		format := spec.Output.Format
+		if format == "" {
+			format = "docker"
+		}
+
+		err := util.Run(log, b.bin, "push", "--digestfile", digestFile, spec.Tags[0], format+"-archive:"+spec.Output.File+":"+spec.Tags[0])
+		return readDigest(digestFile), err
+	}
+
+	if spec.Output.Local {
+		// Images are kept in the local container storage of Podman/Buildah.
+		return "", nil
+	}
+
+	for i, tag := range spec.Tags {
+		args := []string{"push"}
+		if multiPlatform {
+			args = []string{"manifest", "push", "--all"}
+		}
+
+		if i == 0 {
+			args = append(args, "--digestfile", digestFile)
+		}
+
+		if spec.isInsecure(tag) {
+			args = append(args, "--tls-verify=false")
+		}
+
+		if multiPlatform {
+			args = append(args, spec.Tags[0], "docker://"+tag)
+		} else {
+			args = append(args, tag)
+		}
+
+		if err := util.Run(log, b.bin, args...); err != nil {
+			return "", err
+		}
+	}
+
+	return readDigest(digestFile), nil
+}
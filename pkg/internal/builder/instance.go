@@ -0,0 +1,123 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// The state of a buildx builder instance as reported by buildx.
+type InstanceStatus struct {
+	Name        string   `json:"name"`
+	Driver      string   `json:"driver"`
+	Status      string   `json:"status"`
+	Platforms   []string `json:"platforms"`
+	CacheExport bool     `json:"cacheExport"`
+}
+
+// Returns the state of a buildx builder instance, or of the currently
+// selected builder if no name is given.
+func InspectInstance(log *util.Logger, name string) (*InstanceStatus, error) {
+	args := []string{"buildx", "inspect", "--debug"}
+	if name != "" {
+		args = append(args, name)
+	}
+
+	output, err := util.CaptureWithoutStdErr(log, "docker", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseInspect(string(output)), nil
+}
+
+func parseInspect(output string) *InstanceStatus {
+	status := &InstanceStatus{}
+	cacheExport := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Name":
+			if status.Name == "" {
+				status.Name = value
+			}
+		case "Driver":
+			if status.Driver == "" {
+				status.Driver = value
+			}
+		case "Status":
+			if status.Status == "" {
+				status.Status = value
+			}
+		case "Platforms":
+			if status.Platforms == nil {
+				for _, platform := range strings.Split(value, ",") {
+					if platform = strings.TrimSuffix(strings.TrimSpace(platform), "*"); platform != "" {
+						status.Platforms = append(status.Platforms, platform)
+					}
+				}
+			}
+		case "Cache export":
+			cacheExport = value
+		}
+	}
+
+	if cacheExport != "" {
+		status.CacheExport = strings.Contains(cacheExport, "true")
+	} else {
+		// The docker driver cannot export cache to a registry.
+		status.CacheExport = status.Driver != "" && status.Driver != "docker"
+	}
+
+	return status
+}
+
+// Creates a buildx builder instance and starts it.
+func CreateInstance(log *util.Logger, inst config.BuildxBuilder) error {
+	args := []string{"buildx", "create", "--name", inst.Name, "--driver", inst.Driver}
+
+	if len(inst.Platforms) > 0 {
+		args = append(args, "--platform", strings.Join(inst.Platforms, ","))
+	}
+
+	keys := make([]string, 0, len(inst.DriverOpts))
+	for key := range inst.DriverOpts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		args = append(args, "--driver-opt", key+"="+inst.DriverOpts[key])
+	}
+
+	return util.Run(log, "docker", append(args, "--bootstrap")...)
+}
+
+func RemoveInstance(log *util.Logger, name string) error {
+	return util.Run(log, "docker", "buildx", "rm", name)
+}
+
+// Creates the buildx builder instance if it does not exist yet. An existing
+// instance is reused, even if it was created with other options.
+func EnsureInstance(log *util.Logger, inst config.BuildxBuilder) error {
+	status, err := InspectInstance(log, inst.Name)
+	if err != nil {
+		log.Note("Creating buildx builder", inst.Name, "with driver", inst.Driver)
+		return CreateInstance(log, inst)
+	}
+
+	if status.Driver != inst.Driver {
+		log.Warn(fmt.Sprintf("Builder %s uses driver %s instead of %s, recreate it with 'kd builder rm' and 'kd builder create'", inst.Name, status.Driver, inst.Driver))
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"os"
+
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Builds images with the Kaniko executor, which runs inside a container
+// without a Docker daemon. Kaniko always builds for the platform it runs on,
+// and can only push to a registry or write a Docker tarball.
+type kaniko struct{}
+
+func (b *kaniko) Name() string {
+	return "kaniko"
+}
+
+func (b *kaniko) SupportsCacheExport(log *util.Logger) bool {
+	return true
+}
+
+func (b *kaniko) Build(log *util.Logger, spec *Spec) (string, error) {
+	if spec.Provenance || spec.SBOM {
+		return "", unsupported(b, "provenance and SBOM attestations")
+	}
+
+	if len(spec.Secrets) > 0 {
+		return "", unsupported(b, "build secrets")
+	}
+
+	if spec.Output.Local && spec.Output.File == "" {
+		return "", unsupported(b, "loading images into a local image store, use --output-file instead")
+	}
+
+	if spec.Output.Format == "oci" {
+		return "", unsupported(b, "exporting OCI tarballs")
+	}
+
+	if len(spec.Platforms) > 1 {
+		return "", unsupported(b, "building for multiple platforms")
+	}
+
+	if spec.SSH {
+		log.Warn("The kaniko builder does not support SSH forwarding, SSH keys will not be available")
+	}
+
+	cmd := []string{
+		"--dockerfile", spec.Dockerfile,
+		"--context", "dir://" + spec.Context,
+	}
+
+	if len(spec.Platforms) > 0 {
+		cmd = append(cmd, "--custom-platform", spec.Platforms[0])
+	}
+
+	cacheRepo := spec.CacheTo
+	if cacheRepo == "" && len(spec.CacheFrom) > 0 {
+		cacheRepo = spec.CacheFrom[0]
+	}
+
+	if spec.CacheTo != "" && cacheMode(spec) != "max" {
+		log.Warn("The kaniko builder does not support cache mode", spec.CacheMode+", exporting cache for all layers")
+	}
+
+	if cacheRepo != "" {
+		cmd = append(cmd, "--cache=true", "--cache-repo", repositoryOf(cacheRepo))
+		if spec.CacheTo == "" {
+			cmd = append(cmd, "--no-push-cache")
+		}
+	}
+
+	for _, tag := range spec.Tags {
+		cmd = append(cmd, "--destination", tag)
+	}
+
+	for _, host := range spec.InsecureRegistries {
+		cmd = append(cmd, "--insecure-registry", host)
+	}
+
+	for _, label := range sortedLabels(spec.Labels) {
+		cmd = append(cmd, "--label", label)
+	}
+
+	if spec.Output.Local {
+		cmd = append(cmd, "--no-push", "--tar-path", spec.Output.File)
+	}
+
+	digestFile, err := tempFile("kd-digest-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(digestFile)
+
+	cmd = append(cmd, "--digest-file", digestFile)
+	if err := util.Run(log, "executor", cmd...); err != nil {
+		return "", err
+	}
+
+	return readDigest(digestFile), nil
+}
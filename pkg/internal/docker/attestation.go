@@ -0,0 +1,175 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Annotations that BuildKit uses to link attestation manifests in an image
+// index to the image manifest they describe.
+const (
+	annotationReferenceType   = "vnd.docker.reference.type"
+	annotationReferenceDigest = "vnd.docker.reference.digest"
+	annotationPredicateType   = "in-toto.io/predicate-type"
+	referenceTypeAttestation  = "attestation-manifest"
+)
+
+// An in-toto attestation statement.
+type Statement struct {
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// A single platform image in a remote repository, with its configuration
+// labels and any attestations that are attached to it.
+type PlatformImage struct {
+	Platform   string
+	Digest     string
+	Labels     map[string]string
+	Statements []Statement
+}
+
+// Retrieves the platform images of an image, including their attestations.
+func InspectImage(log *util.Logger, img Image) ([]PlatformImage, error) {
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, img.Named, actionsPull)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := img.Manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		image, err := repo.platformImage(ctx, img.Manifest, img.Descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+		return []PlatformImage{image}, nil
+	}
+
+	var images []PlatformImage
+	attestations := map[digest.Digest][]digest.Digest{}
+
+	for _, desc := range list.Manifests {
+		if desc.Annotations[annotationReferenceType] == referenceTypeAttestation {
+			subject := digest.Digest(desc.Annotations[annotationReferenceDigest])
+			attestations[subject] = append(attestations[subject], desc.Digest)
+			continue
+		}
+
+		log.Debug("Retrieving remote manifest", img.Named.Name()+"@"+desc.Digest.String())
+		manifest, err := repo.manifest(ctx, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		image, err := repo.platformImage(ctx, manifest, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		image.Platform = formatPlatform(desc.Platform)
+		images = append(images, image)
+	}
+
+	for i := range images {
+		for _, dgst := range attestations[digest.Digest(images[i].Digest)] {
+			log.Debug("Retrieving attestations", img.Named.Name()+"@"+dgst.String())
+			statements, err := repo.statements(ctx, dgst)
+			if err != nil {
+				return nil, err
+			}
+			images[i].Statements = append(images[i].Statements, statements...)
+		}
+	}
+
+	return images, nil
+}
+
+func (repo *repository) platformImage(ctx context.Context, manifest distribution.Manifest, dgst digest.Digest) (PlatformImage, error) {
+	image := PlatformImage{Digest: dgst.String()}
+
+	var config distribution.Descriptor
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		config = m.Config
+	case *ocischema.DeserializedManifest:
+		config = m.Config
+	default:
+		return image, nil
+	}
+
+	bytes, err := repo.Blobs(ctx).Get(ctx, config.Digest)
+	if err != nil {
+		return image, err
+	}
+
+	var conf struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+		Config       struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+
+	if err := json.Unmarshal(bytes, &conf); err != nil {
+		return image, err
+	}
+
+	image.Labels = conf.Config.Labels
+	image.Platform = formatPlatform(manifestlist.PlatformSpec{
+		OS:           conf.OS,
+		Architecture: conf.Architecture,
+		Variant:      conf.Variant,
+	})
+
+	return image, nil
+}
+
+// Retrieves the in-toto statements from an attestation manifest.
+func (repo *repository) statements(ctx context.Context, dgst digest.Digest) ([]Statement, error) {
+	manifest, err := repo.manifest(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []Statement
+	for _, layer := range manifest.References() {
+		if layer.Annotations[annotationPredicateType] == "" {
+			continue
+		}
+
+		bytes, err := repo.Blobs(ctx).Get(ctx, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		var statement Statement
+		if err := json.Unmarshal(bytes, &statement); err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+func formatPlatform(platform manifestlist.PlatformSpec) string {
+	if platform.OS == "" {
+		return ""
+	}
+
+	formatted := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		formatted += "/" + platform.Variant
+	}
+	return formatted
+}
@@ -0,0 +1,140 @@
+package docker
+
+import (
+	"context"
+	"io"
+
+	"github.com/distribution/reference"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/pkg/errors"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Copies an image to another repository, usually in another registry, and
+// tags it there. All platform images, attestations and layers of an image
+// index are copied, as well as the signatures of the image. The copy has the
+// same digest as the original.
+func CopyImage(log *util.Logger, img Image, location string) (Image, error) {
+	ref, err := reference.ParseNormalizedNamed(location)
+	if err != nil {
+		return Image{}, err
+	}
+
+	tagged, ok := ref.(reference.Tagged)
+	if !ok {
+		ref = reference.TagNameOnly(ref)
+		tagged = ref.(reference.Tagged)
+	}
+
+	ctx := context.Background()
+	src, err := openRepository(ctx, log, img.Named, actionsPull)
+	if err != nil {
+		return Image{}, err
+	}
+
+	dst, err := openRepository(ctx, log, ref, actionsPush)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if err := copyManifest(ctx, log, src, dst, img.Manifest, tagged.Tag()); err != nil {
+		return Image{}, err
+	}
+
+	sigManifest, err := src.signatureManifest(ctx, img.Descriptor.Digest)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if sigManifest != nil {
+		if err := copyManifest(ctx, log, src, dst, sigManifest, signatureTag(img.Descriptor.Digest)); err != nil {
+			return Image{}, err
+		}
+	}
+
+	return Image{
+		Named:      reference.TrimNamed(ref),
+		Descriptor: img.Descriptor,
+		Manifest:   img.Manifest,
+	}, nil
+}
+
+// Copies a manifest and everything it refers to. Manifests that an image index
+// refers to are stored by digest, the manifest itself with the given tag, if
+// any.
+func copyManifest(ctx context.Context, log *util.Logger, src *repository, dst *repository, manifest distribution.Manifest, tag string) error {
+	if list, ok := manifest.(*manifestlist.DeserializedManifestList); ok {
+		for _, desc := range list.Manifests {
+			child, err := src.manifest(ctx, desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := copyManifest(ctx, log, src, dst, child, ""); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, desc := range manifest.References() {
+			if len(desc.URLs) > 0 {
+				// Foreign layers are not stored in the registry.
+				continue
+			}
+
+			if err := copyBlob(ctx, log, src, dst, desc); err != nil {
+				return err
+			}
+		}
+	}
+
+	manifests, err := dst.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	var options []distribution.ManifestServiceOption
+	if tag != "" {
+		options = append(options, distribution.WithTag(tag))
+		log.Debug("Storing remote manifest", dst.Named().Name()+":"+tag)
+	} else {
+		log.Debug("Storing remote manifest", dst.Named().Name())
+	}
+
+	_, err = manifests.Put(ctx, manifest, options...)
+	return err
+}
+
+func copyBlob(ctx context.Context, log *util.Logger, src *repository, dst *repository, desc distribution.Descriptor) error {
+	if _, err := dst.Blobs(ctx).Stat(ctx, desc.Digest); err == nil {
+		return nil
+	} else if !errors.Is(err, distribution.ErrBlobUnknown) {
+		return err
+	}
+
+	log.Debug("Copying blob", desc.Digest)
+	reader, err := src.Blobs(ctx).Open(ctx, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	writer, err := dst.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return err
+	}
+
+	verifier := desc.Digest.Verifier()
+	if _, err := io.Copy(writer, io.TeeReader(reader, verifier)); err != nil {
+		writer.Cancel(ctx)
+		return err
+	}
+
+	if !verifier.Verified() {
+		writer.Cancel(ctx)
+		return errors.Errorf("Blob %s of %s does not match its digest", desc.Digest, src.Named().Name())
+	}
+
+	_, err = writer.Commit(ctx, distribution.Descriptor{Digest: desc.Digest, Size: desc.Size, MediaType: desc.MediaType})
+	return err
+}
@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestCopyImage(t *testing.T) {
+	log := util.NewLogger("test")
+	src := newTestRegistry(t)
+	dst := newTestRegistry(t)
+
+	img := src.pushIndex(t, src.Host()+"/foo:production", "linux/amd64", "linux/arm64")
+	err := AddSignature(log, img, Signature{Payload: []byte("one"), Signature: "c2lnMQ=="})
+	assert.Nil(t, err)
+
+	copied, err := CopyImage(log, img, dst.Host()+"/mirror/foo:production")
+	assert.Nil(t, err)
+	assert.Equal(t, img.Descriptor.Digest, copied.Descriptor.Digest)
+	assert.Equal(t, dst.Host()+"/mirror/foo", copied.Named.Name())
+
+	mirrored, err := GetImage(log, dst.Host()+"/mirror/foo:production")
+	assert.Nil(t, err)
+	assert.Equal(t, img.Descriptor.Digest, mirrored.Descriptor.Digest)
+
+	platforms, err := ImagePlatforms(log, mirrored)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, platforms)
+
+	signatures, err := GetSignatures(log, mirrored)
+	assert.Nil(t, err)
+	assert.Len(t, signatures, 1)
+
+	for dgst := range src.blobs {
+		assert.Contains(t, dst.blobs, dgst)
+	}
+}
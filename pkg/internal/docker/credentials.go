@@ -0,0 +1,226 @@
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/docker/docker/api/types/registry"
+	dockerregistry "github.com/docker/docker/registry"
+	"github.com/pkg/errors"
+	kdconfig "github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+const (
+	// Username for access tokens, as expected by Google registries. Other
+	// registries accept any username with a token.
+	tokenUsername = "oauth2accesstoken"
+
+	// Username for service account key files of Google registries.
+	keyFileUsername = "_json_key"
+
+	// Username that credential helpers return for identity tokens.
+	identityTokenUsername = "<token>"
+)
+
+// How kd accesses registries, from the 'registries' section of kdeploy.conf.
+var registryOptions []kdconfig.RegistryOptions
+
+// Configures how kd authenticates with registries, and which registries are
+// accessed over plain HTTP. Registries that are not configured use the Docker
+// configuration file.
+func ConfigureRegistries(options []kdconfig.RegistryOptions) {
+	registryOptions = options
+}
+
+func optionsFor(host string) (kdconfig.RegistryOptions, bool) {
+	for _, options := range registryOptions {
+		if options.Host == host {
+			return options, true
+		}
+	}
+	return kdconfig.RegistryOptions{Host: host}, false
+}
+
+// Credentials for a registry, with a description of where they came from.
+type credentialSource struct {
+	registry.AuthConfig
+	source string
+}
+
+func resolveCredentials(log *util.Logger, index *registry.IndexInfo) (credentialSource, error) {
+	options, ok := optionsFor(index.Name)
+	if !ok {
+		return dockerConfigCredentials(log, index)
+	}
+
+	var creds credentialSource
+	switch {
+	case options.Anonymous:
+		creds.source = "anonymous access"
+
+	case options.CredentialHelper != "":
+		helper := "docker-credential-" + options.CredentialHelper
+		creds.source = "credential helper '" + helper + "'"
+		auth, err := helperCredentials(helper, index.Name)
+		if err != nil {
+			return creds, errors.Wrapf(err, "Could not get credentials for %s from %s", index.Name, creds.source)
+		}
+		creds.AuthConfig = auth
+
+	case options.TokenEnv != "":
+		creds.source = "token in $" + options.TokenEnv
+		token := os.Getenv(options.TokenEnv)
+		if token == "" {
+			return creds, fmt.Errorf("Could not get credentials for %s, because $%s is not set", index.Name, options.TokenEnv)
+		}
+
+		creds.Username = options.Username
+		if creds.Username == "" {
+			creds.Username = tokenUsername
+		}
+		creds.Password = token
+
+	case options.KeyFile != "":
+		creds.source = "service account key file '" + options.KeyFile + "'"
+		key, err := os.ReadFile(expandHome(options.KeyFile))
+		if err != nil {
+			return creds, errors.Wrapf(err, "Could not get credentials for %s from %s", index.Name, creds.source)
+		}
+
+		creds.Username = keyFileUsername
+		creds.Password = string(key)
+
+	default:
+		return dockerConfigCredentials(log, index)
+	}
+
+	log.Debug("Using", creds.source, "for", index.Name)
+	return creds, nil
+}
+
+// Reads credentials from the Docker configuration file, which may refer to a
+// credential helper or credential store.
+func dockerConfigCredentials(log *util.Logger, index *registry.IndexInfo) (credentialSource, error) {
+	conf := config.LoadDefaultConfigFile(os.Stderr)
+
+	creds := credentialSource{source: "credentials from " + conf.Filename}
+	if helper := conf.CredentialHelpers[index.Name]; helper != "" {
+		creds.source = "credential helper 'docker-credential-" + helper + "' from " + conf.Filename
+	} else if conf.CredentialsStore != "" {
+		creds.source = "credential store 'docker-credential-" + conf.CredentialsStore + "' from " + conf.Filename
+	}
+
+	// Credentials for Docker Hub are stored under the URL of its index.
+	key := index.Name
+	if index.Official {
+		key = dockerregistry.IndexServer
+	}
+
+	auth, err := conf.GetAuthConfig(key)
+	if err != nil {
+		return creds, errors.Wrapf(err, "Could not get credentials for %s from %s", index.Name, creds.source)
+	}
+	creds.AuthConfig = registry.AuthConfig(auth)
+
+	if creds.isEmpty() {
+		creds.source = "no credentials"
+	}
+
+	log.Debug("Using", creds.source, "for", index.Name)
+	return creds, nil
+}
+
+func helperCredentials(helper string, host string) (registry.AuthConfig, error) {
+	creds, err := client.Get(client.NewShellProgramFunc(helper), host)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return registry.AuthConfig{}, nil
+	} else if err != nil {
+		return registry.AuthConfig{}, err
+	}
+
+	if creds.Username == identityTokenUsername {
+		return registry.AuthConfig{IdentityToken: creds.Secret}, nil
+	}
+	return registry.AuthConfig{Username: creds.Username, Password: creds.Secret}, nil
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// An error that explains that a registry denied access, and which credentials
+// were used.
+type AuthError struct {
+	Registry string
+	Source   string
+	Reason   string
+}
+
+func (err *AuthError) Error() string {
+	msg := fmt.Sprintf("Registry %s denied access with %s (%s)", err.Registry, err.Source, err.Reason)
+	if err.Source == "no credentials" {
+		return msg + "; log in with 'docker login " + err.Registry + "' or configure credentials in 'registries' in kdeploy.conf"
+	}
+	return msg + "; check that these credentials are valid and have access to the repository"
+}
+
+// Turns responses and token errors that deny access into an AuthError, so
+// that it is clear which credentials were rejected. Responses only count as
+// denied if credentials were sent with the request: registries such as Docker
+// Hub also respond with 401 to anonymous requests for repositories that do not
+// exist.
+type authCheck struct {
+	base        http.RoundTripper
+	registry    string
+	source      string
+	credentials bool
+}
+
+func (tr *authCheck) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := tr.base.RoundTrip(req)
+	if err != nil {
+		if isDenied(err) {
+			return nil, &AuthError{tr.registry, tr.source, err.Error()}
+		}
+		return nil, err
+	}
+
+	denied := resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+	if denied && tr.credentials && req.Header.Get("Authorization") != "" {
+		resp.Body.Close()
+		return nil, &AuthError{tr.registry, tr.source, resp.Status}
+	}
+
+	return resp, nil
+}
+
+// Reports whether any credentials were found for a registry.
+func (creds credentialSource) isEmpty() bool {
+	return creds.Username == "" && creds.Password == "" && creds.IdentityToken == "" && creds.RegistryToken == ""
+}
+
+func isDenied(err error) bool {
+	var errs errcode.Errors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		err = errs[0]
+	}
+
+	var code errcode.Error
+	if errors.As(err, &code) {
+		return code.Code == errcode.ErrorCodeUnauthorized || code.Code == errcode.ErrorCodeDenied
+	}
+	return false
+}
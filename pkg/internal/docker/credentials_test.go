@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func configureRegistries(t *testing.T, options ...config.RegistryOptions) {
+	ConfigureRegistries(options)
+	t.Cleanup(func() { ConfigureRegistries(nil) })
+}
+
+func TestResolveTokenCredentials(t *testing.T) {
+	configureRegistries(t, config.RegistryOptions{Host: "ghcr.io", TokenEnv: "KD_TEST_TOKEN"})
+	index := &registry.IndexInfo{Name: "ghcr.io"}
+
+	_, err := resolveCredentials(util.NewLogger("test"), index)
+	assert.Equal(t, "Could not get credentials for ghcr.io, because $KD_TEST_TOKEN is not set", err.Error())
+
+	t.Setenv("KD_TEST_TOKEN", "secret")
+	creds, err := resolveCredentials(util.NewLogger("test"), index)
+	assert.Nil(t, err)
+	assert.Equal(t, "oauth2accesstoken", creds.Username)
+	assert.Equal(t, "secret", creds.Password)
+	assert.Equal(t, "token in $KD_TEST_TOKEN", creds.source)
+}
+
+func TestResolveKeyFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.json")
+	os.WriteFile(path, []byte(`{"type":"service_account"}`), 0600)
+
+	configureRegistries(t, config.RegistryOptions{Host: "eu.gcr.io", KeyFile: path})
+	creds, err := resolveCredentials(util.NewLogger("test"), &registry.IndexInfo{Name: "eu.gcr.io"})
+	assert.Nil(t, err)
+	assert.Equal(t, "_json_key", creds.Username)
+	assert.Equal(t, `{"type":"service_account"}`, creds.Password)
+}
+
+func TestResolveMissingCredentialHelper(t *testing.T) {
+	configureRegistries(t, config.RegistryOptions{Host: "eu.gcr.io", CredentialHelper: "kd-test-missing"})
+	_, err := resolveCredentials(util.NewLogger("test"), &registry.IndexInfo{Name: "eu.gcr.io"})
+	assert.True(t, strings.HasPrefix(err.Error(), "Could not get credentials for eu.gcr.io from credential helper 'docker-credential-kd-test-missing'"))
+}
+
+func TestAuthError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv("KD_TEST_TOKEN", "secret")
+	configureRegistries(t, config.RegistryOptions{Host: host, TokenEnv: "KD_TEST_TOKEN"})
+
+	_, err := GetImage(util.NewLogger("test"), host+"/foo:latest")
+
+	var authErr *AuthError
+	if assert.True(t, errors.As(err, &authErr)) {
+		assert.Equal(t, host, authErr.Registry)
+		assert.Equal(t, "token in $KD_TEST_TOKEN", authErr.Source)
+	}
+}
+
+func TestAnonymousUnauthorizedIsNotAuthError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	configureRegistries(t, config.RegistryOptions{Host: host, Anonymous: true})
+
+	_, err := GetImage(util.NewLogger("test"), host+"/foo:latest")
+
+	var authErr *AuthError
+	assert.NotNil(t, err)
+	assert.False(t, errors.As(err, &authErr))
+}
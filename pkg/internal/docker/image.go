@@ -2,50 +2,126 @@ package docker
 
 import (
 	"context"
-	"os"
 	"runtime"
 
 	"github.com/distribution/reference"
-	"github.com/docker/cli/cli/config"
-	manifesttypes "github.com/docker/cli/cli/manifest/types"
-	"github.com/docker/cli/cli/registry/client"
-	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
 	"github.com/voormedia/kd/pkg/util"
 )
 
-type ImageManifest = manifesttypes.ImageManifest
+// An image manifest or image index in a remote repository.
+type Image struct {
+	Named      reference.Named
+	Descriptor distribution.Descriptor
+	Manifest   distribution.Manifest
+}
 
-func GetImage(log *util.Logger, location string) (ImageManifest, error) {
+func GetImage(log *util.Logger, location string) (Image, error) {
 	ref, err := reference.ParseNormalizedNamed(location)
 	if err != nil {
-		return ImageManifest{}, err
+		return Image{}, err
 	}
 
 	ctx := context.Background()
+	repo, err := openRepository(ctx, log, ref, actionsPull)
+	if err != nil {
+		return Image{}, err
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return Image{}, err
+	}
+
+	var dgst digest.Digest
+	var options []distribution.ManifestServiceOption
+	if canonical, ok := ref.(reference.Canonical); ok {
+		dgst = canonical.Digest()
+	} else if tagged, ok := ref.(reference.Tagged); ok {
+		options = append(options, distribution.WithTag(tagged.Tag()))
+	} else {
+		options = append(options, distribution.WithTag("latest"))
+	}
+
 	log.Debug("Retrieving remote manifest", ref)
-	return newClient().GetManifest(ctx, ref)
+	manifest, err := manifests.Get(ctx, dgst, options...)
+	if err != nil {
+		return Image{}, err
+	}
+
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		Named: reference.TrimNamed(ref),
+		Descriptor: distribution.Descriptor{
+			MediaType: mediaType,
+			Size:      int64(len(payload)),
+			Digest:    digest.FromBytes(payload),
+		},
+		Manifest: manifest,
+	}, nil
 }
 
-func TagImage(log *util.Logger, manifest ImageManifest, location string) error {
+func TagImage(log *util.Logger, img Image, location string) error {
 	ref, err := reference.ParseNormalizedNamed(location)
 	if err != nil {
 		return err
 	}
 
+	tagged, ok := ref.(reference.Tagged)
+	if !ok {
+		ref = reference.TagNameOnly(ref)
+		tagged = ref.(reference.Tagged)
+	}
+
 	ctx := context.Background()
+	repo, err := openRepository(ctx, log, ref, actionsPush)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
 	log.Debug("Storing remote manifest", ref)
-	_, err = newClient().PutManifest(ctx, ref, manifest)
+	_, err = manifests.Put(ctx, img.Manifest, distribution.WithTag(tagged.Tag()))
 	return err
 }
 
-func newClient() client.RegistryClient {
-	return client.NewRegistryClient(resolver, userAgent, false)
-}
+// Returns the platforms of an image. An image index can contain images for
+// multiple platforms; attestation manifests in it are skipped.
+func ImagePlatforms(log *util.Logger, img Image) ([]string, error) {
+	if list, ok := img.Manifest.(*manifestlist.DeserializedManifestList); ok {
+		var platforms []string
+		for _, desc := range list.Manifests {
+			if desc.Annotations[annotationReferenceType] == referenceTypeAttestation || desc.Platform.OS == "unknown" {
+				continue
+			}
+			platforms = append(platforms, formatPlatform(desc.Platform))
+		}
+		return platforms, nil
+	}
+
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, img.Named, actionsPull)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Retrieving image configuration", img.Named.Name()+"@"+img.Descriptor.Digest.String())
+	image, err := repo.platformImage(ctx, img.Manifest, img.Descriptor.Digest)
+	if err != nil {
+		return nil, err
+	}
 
-func resolver(ctx context.Context, index *registry.IndexInfo) registry.AuthConfig {
-	conf := config.LoadDefaultConfigFile(os.Stderr)
-	authConfig, _ := conf.GetAuthConfig(index.Name)
-	return registry.AuthConfig(authConfig)
+	return []string{image.Platform}, nil
 }
 
 const userAgent = "KD (" + runtime.GOOS + ")"
@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestGetAndTagImageIndex(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+	pushed := reg.pushIndex(t, reg.Host()+"/foo:latest", "linux/amd64", "linux/arm64")
+
+	img, err := GetImage(log, reg.Host()+"/foo:latest")
+	assert.Nil(t, err)
+	assert.IsType(t, &manifestlist.DeserializedManifestList{}, img.Manifest)
+	assert.Equal(t, pushed.Descriptor.Digest, img.Descriptor.Digest)
+
+	err = TagImage(log, img, reg.Host()+"/foo:production")
+	assert.Nil(t, err)
+
+	tagged, err := GetImage(log, reg.Host()+"/foo@"+img.Descriptor.Digest.String())
+	assert.Nil(t, err)
+	assert.Equal(t, img.Descriptor.Digest, tagged.Descriptor.Digest)
+	assert.Equal(t, img.Descriptor.Digest.String(), reg.tags["foo"]["production"])
+
+	platforms, err := ImagePlatforms(log, img)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, platforms)
+}
+
+func TestImagePlatformsOfSingleImage(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+	img := reg.pushImage(t, reg.Host()+"/foo:latest")
+
+	platforms, err := ImagePlatforms(log, img)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"linux/amd64"}, platforms)
+}
+
+func TestGetMissingImage(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+	reg.pushImage(t, reg.Host()+"/foo:latest")
+
+	_, err := GetImage(log, reg.Host()+"/foo:production")
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+const labelRevision = "org.opencontainers.image.revision"
+
+// An image in a remote repository, with all tags that refer to it. Created is
+// nil if the registry does not report when the image was created.
+type ImageDetails struct {
+	Digest    string     `json:"digest"`
+	Tags      []string   `json:"tags"`
+	Created   *time.Time `json:"created,omitempty"`
+	Size      int64      `json:"size"`
+	Platforms []string   `json:"platforms"`
+	Revision  string     `json:"revision,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+}
+
+// Returns when the image was created, or the zero time if it is unknown.
+func (image ImageDetails) CreatedAt() time.Time {
+	if image.Created == nil {
+		return time.Time{}
+	}
+	return *image.Created
+}
+
+// Lists the images in a repository, most recently created first. The size of
+// an image is the total compressed size of its layers for all platforms.
+// Signatures are not listed as images, but with the image they sign.
+func ListImages(log *util.Logger, location string) ([]ImageDetails, error) {
+	tags, err := ListTags(log, location)
+	if err != nil {
+		return nil, err
+	}
+
+	named, err := reference.ParseNormalizedNamed(location)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, named, actionsPull)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*ImageDetails
+	byDigest := map[string]*ImageDetails{}
+	signatures := map[string]string{}
+	for _, tag := range tags {
+		if signed, ok := signedDigest(tag.Name); ok {
+			signatures[signed] = tag.Digest
+			continue
+		}
+
+		image := byDigest[tag.Digest]
+		if image == nil {
+			image = &ImageDetails{Digest: tag.Digest}
+			byDigest[tag.Digest] = image
+			images = append(images, image)
+		}
+
+		image.Tags = append(image.Tags, tag.Name)
+		if tag.CreatedAt().After(image.CreatedAt()) {
+			image.Created = tag.Created
+		}
+	}
+
+	for signed, dgst := range signatures {
+		if image := byDigest[signed]; image != nil {
+			image.Signature = dgst
+		} else {
+			// Keep signatures of images that no longer exist visible.
+			tag := signatureTag(digest.Digest(signed))
+			image = &ImageDetails{Digest: dgst, Tags: []string{tag}}
+			byDigest[dgst] = image
+			images = append(images, image)
+		}
+	}
+
+	result := make([]ImageDetails, 0, len(images))
+	for _, image := range images {
+		log.Debug("Retrieving remote manifest", named.Name()+"@"+image.Digest)
+		manifest, err := repo.manifest(ctx, digest.Digest(image.Digest))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := repo.describe(ctx, image, manifest); err != nil {
+			return nil, err
+		}
+		result = append(result, *image)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt().After(result[j].CreatedAt())
+	})
+
+	return result, nil
+}
+
+// Returns the size, platforms and revision of a single image.
+func DescribeImage(log *util.Logger, img Image) (ImageDetails, error) {
+	image := ImageDetails{Digest: img.Descriptor.Digest.String()}
+
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, img.Named, actionsPull)
+	if err != nil {
+		return image, err
+	}
+
+	log.Debug("Retrieving image configuration", img.Named.Name()+"@"+image.Digest)
+	err = repo.describe(ctx, &image, img.Manifest)
+	return image, err
+}
+
+// Deletes an image with all its tags, and its signature.
+func DeleteImage(log *util.Logger, location string, image ImageDetails) error {
+	if err := DeleteTags(log, location, image.Digest, image.Tags); err != nil {
+		return err
+	}
+
+	if image.Signature != "" {
+		tag := signatureTag(digest.Digest(image.Digest))
+		return DeleteTags(log, location, image.Signature, []string{tag})
+	}
+
+	return nil
+}
+
+// Returns the digest of the image that a signature tag refers to.
+func signedDigest(tag string) (string, bool) {
+	name, ok := strings.CutSuffix(tag, signatureTagSuffix)
+	if !ok {
+		return "", false
+	}
+
+	dgst, err := digest.Parse(strings.Replace(name, "-", ":", 1))
+	if err != nil {
+		return "", false
+	}
+	return dgst.String(), true
+}
+
+// Adds the size, platforms and revision of a manifest or image index to the
+// details of an image.
+func (repo *repository) describe(ctx context.Context, image *ImageDetails, manifest distribution.Manifest) error {
+	list, ok := manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		platform, err := repo.platformImage(ctx, manifest, digest.Digest(image.Digest))
+		if err != nil {
+			return err
+		}
+
+		image.Size = layerSize(manifest)
+		image.Platforms = []string{platform.Platform}
+		image.Revision = platform.Labels[labelRevision]
+		return nil
+	}
+
+	for _, desc := range list.Manifests {
+		if desc.Annotations[annotationReferenceType] == referenceTypeAttestation || desc.Platform.OS == "unknown" {
+			continue
+		}
+
+		child, err := repo.manifest(ctx, desc.Digest)
+		if err != nil {
+			return err
+		}
+
+		image.Size += layerSize(child)
+		image.Platforms = append(image.Platforms, formatPlatform(desc.Platform))
+
+		if image.Revision == "" {
+			platform, err := repo.platformImage(ctx, child, desc.Digest)
+			if err != nil {
+				return err
+			}
+			image.Revision = platform.Labels[labelRevision]
+		}
+	}
+
+	return nil
+}
+
+func layerSize(manifest distribution.Manifest) (size int64) {
+	for _, desc := range manifest.References() {
+		size += desc.Size
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/distribution/manifest/manifestlist"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestListTagsOfBuildCache(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+	ctx := context.Background()
+
+	ref, _ := reference.ParseNormalizedNamed(reg.Host() + "/foo/build-cache:main")
+	repo, err := openRepository(ctx, log, ref, actionsPush)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := repo.Blobs(ctx).Put(ctx, v1.MediaTypeImageLayerGzip, []byte("layer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := repo.Blobs(ctx).Put(ctx, mediaTypeCacheConfig, []byte(`{"layers":[
+		{"blob":"`+layer.Digest.String()+`","annotations":{"createdAt":"2024-05-01T10:00:00Z"}},
+		{"blob":"`+layer.Digest.String()+`","annotations":{"createdAt":"2024-05-02T10:00:00Z"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer.MediaType = v1.MediaTypeImageLayerGzip
+	config.MediaType = mediaTypeCacheConfig
+	index, err := manifestlist.FromDescriptorsWithMediaType([]manifestlist.ManifestDescriptor{
+		{Descriptor: layer},
+		{Descriptor: config},
+	}, v1.MediaTypeImageIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := Image{Named: reference.TrimNamed(ref), Manifest: index}
+	if err := TagImage(log, img, ref.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := ListTags(log, reg.Host()+"/foo/build-cache")
+	assert.Nil(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC), tags[0].CreatedAt().UTC())
+	}
+
+	// Blobs are never requested as manifests.
+	assert.Empty(t, reg.missing)
+}
+
+func TestListImages(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+
+	index := reg.pushIndex(t, reg.Host()+"/foo:latest", "linux/amd64", "linux/arm64")
+	if err := TagImage(log, index, reg.Host()+"/foo:production"); err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := ListImages(log, reg.Host()+"/foo")
+	assert.Nil(t, err)
+
+	var found *ImageDetails
+	for i := range images {
+		if images[i].Digest == index.Descriptor.Digest.String() {
+			found = &images[i]
+		}
+	}
+
+	if assert.NotNil(t, found) {
+		assert.Equal(t, []string{"latest", "production"}, found.Tags)
+		assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, found.Platforms)
+		assert.Greater(t, found.Size, int64(0))
+	}
+}
+
+func TestListAndDeleteSignedImage(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+
+	img := reg.pushImage(t, reg.Host()+"/foo:old")
+	err := AddSignature(log, img, Signature{Payload: []byte("one"), Signature: "c2lnMQ=="})
+	assert.Nil(t, err)
+
+	images, err := ListImages(log, reg.Host()+"/foo")
+	assert.Nil(t, err)
+
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, []string{"old"}, images[0].Tags)
+		assert.NotEmpty(t, images[0].Signature)
+
+		err = DeleteImage(log, reg.Host()+"/foo", images[0])
+		assert.Nil(t, err)
+		assert.Empty(t, reg.tags["foo"])
+	}
+}
+
+func TestTagWithUnknownAge(t *testing.T) {
+	bytes, err := json.Marshal(Tag{Name: "main", Digest: "sha256:1"})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"tag":"main","digest":"sha256:1"}`, string(bytes))
+	assert.True(t, Tag{}.CreatedAt().IsZero())
+}
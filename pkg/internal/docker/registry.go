@@ -0,0 +1,492 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	distributionclient "github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/docker/docker/registry"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	kdconfig "github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// A tag in a remote repository. Created is nil if the registry does not
+// report when the image was created.
+type Tag struct {
+	Name    string     `json:"tag"`
+	Digest  string     `json:"digest"`
+	Created *time.Time `json:"created,omitempty"`
+}
+
+// Returns when the image of the tag was created, or the zero time if it is
+// unknown.
+func (tag Tag) CreatedAt() time.Time {
+	if tag.Created == nil {
+		return time.Time{}
+	}
+	return *tag.Created
+}
+
+// Returns a pointer to the time, or nil if it is the zero time.
+func knownTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Media type of the configuration of build cache exported by BuildKit.
+const mediaTypeCacheConfig = "application/vnd.buildkit.cacheconfig.v0"
+
+var (
+	actionsPull   = []string{"pull"}
+	actionsPush   = []string{"pull", "push"}
+	actionsDelete = []string{"pull", "push", "delete"}
+)
+
+// A repository in a remote registry. Wraps the distribution client with the
+// few registry API calls it does not implement.
+type repository struct {
+	distribution.Repository
+	baseURL string
+	client  *http.Client
+}
+
+func openRepository(ctx context.Context, log *util.Logger, named reference.Named, actions []string) (*repository, error) {
+	service, err := registry.NewService(registry.ServiceOptions{
+		InsecureRegistries: kdconfig.InsecureRegistries(registryOptions),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repoInfo, err := service.ResolveRepository(named)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := service.LookupPushEndpoints(reference.Domain(repoInfo.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := endpoints[0]
+	if !repoInfo.Index.Secure {
+		for _, ep := range endpoints {
+			if ep.URL.Scheme == "http" {
+				endpoint = ep
+			}
+		}
+	}
+
+	repoName := repoInfo.Name.Name()
+	if endpoint.TrimHostname {
+		repoName = reference.Path(repoInfo.Name)
+	}
+
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     endpoint.TLSConfig,
+		DisableKeepAlives:   true,
+	}
+
+	modifiers := registry.Headers(userAgent, http.Header{})
+	authTransport := transport.NewTransport(base, modifiers...)
+	challengeManager, err := registry.PingV2Registry(endpoint.URL, authTransport)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not connect to registry %s", reference.Domain(named))
+	}
+
+	authConfig, err := resolveCredentials(log, repoInfo.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := registry.NewStaticCredentialStore(&authConfig.AuthConfig)
+	tokenHandler := auth.NewTokenHandler(authTransport, creds, repoName, actions...)
+	basicHandler := auth.NewBasicHandler(creds)
+	modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler))
+
+	// Responses are checked below the authorizer, which adds the Authorization
+	// header to a copy of the request. Token errors are returned by the
+	// authorizer itself.
+	check := func(base http.RoundTripper) *authCheck {
+		return &authCheck{
+			base:        base,
+			registry:    reference.Domain(named),
+			source:      authConfig.source,
+			credentials: !authConfig.isEmpty(),
+		}
+	}
+	tr := check(transport.NewTransport(check(base), modifiers...))
+
+	name, err := reference.WithName(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := distributionclient.NewRepository(name, endpoint.URL.String(), tr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository{
+		Repository: repo,
+		baseURL:    endpoint.URL.String() + "/v2/" + repoName,
+		client:     &http.Client{Transport: tr},
+	}, nil
+}
+
+// Response of the tags list endpoint. Google Container Registry and Artifact
+// Registry extend it with the digests and upload times of all manifests.
+type tagList struct {
+	Tags     []string `json:"tags"`
+	Manifest map[string]struct {
+		Tag            []string `json:"tag"`
+		TimeCreatedMs  string   `json:"timeCreatedMs"`
+		TimeUploadedMs string   `json:"timeUploadedMs"`
+	} `json:"manifest"`
+}
+
+func (repo *repository) listTags(ctx context.Context) ([]Tag, error) {
+	tags := map[string]*Tag{}
+	next := repo.baseURL + "/tags/list"
+
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := repo.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		} else if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Could not list tags of %s: %s", repo.Named(), resp.Status)
+		}
+
+		var list tagList
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+
+		for _, name := range list.Tags {
+			if tags[name] == nil {
+				tags[name] = &Tag{Name: name}
+			}
+		}
+
+		for dgst, details := range list.Manifest {
+			created := parseMillis(details.TimeUploadedMs)
+			if created.IsZero() {
+				created = parseMillis(details.TimeCreatedMs)
+			}
+
+			for _, name := range details.Tag {
+				tags[name] = &Tag{Name: name, Digest: dgst, Created: knownTime(created)}
+			}
+		}
+
+		next, err = nextLink(next, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, *tag)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Deletes a tag without deleting the manifest it refers to. Not all
+// registries support this, in which case the manifest must be deleted.
+func (repo *repository) untag(ctx context.Context, tag string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, repo.baseURL+"/manifests/"+tag, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := repo.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Could not delete tag %s of %s: %s", tag, repo.Named(), resp.Status)
+	}
+
+	return nil
+}
+
+// Returns the creation time from the image configuration, if the manifest
+// refers to an image, or from the cache configuration, if it refers to build
+// cache exported by BuildKit.
+func (repo *repository) created(ctx context.Context, manifest distribution.Manifest) time.Time {
+	var config distribution.Descriptor
+
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		config = m.Config
+	case *ocischema.DeserializedManifest:
+		config = m.Config
+	case *manifestlist.DeserializedManifestList:
+		// Build cache indexes refer to layer blobs and a cache configuration
+		// blob instead of to manifests.
+		for _, child := range m.Manifests {
+			if child.MediaType == mediaTypeCacheConfig {
+				return repo.cacheCreated(ctx, child.Digest)
+			}
+		}
+
+		for _, child := range m.Manifests {
+			if child.Platform.OS == "unknown" || !isManifest(child.MediaType) {
+				// Attestation manifests and blobs are not images.
+				continue
+			}
+
+			if m, err := repo.manifest(ctx, child.Digest); err == nil {
+				return repo.created(ctx, m)
+			}
+		}
+		return time.Time{}
+	default:
+		return time.Time{}
+	}
+
+	if config.MediaType == mediaTypeCacheConfig {
+		return repo.cacheCreated(ctx, config.Digest)
+	}
+
+	bytes, err := repo.Blobs(ctx).Get(ctx, config.Digest)
+	if err != nil {
+		return time.Time{}
+	}
+
+	var image struct {
+		Created time.Time `json:"created"`
+	}
+
+	json.Unmarshal(bytes, &image)
+	return image.Created
+}
+
+// Returns the time at which the most recent layer in a BuildKit cache
+// configuration was created.
+func (repo *repository) cacheCreated(ctx context.Context, dgst digest.Digest) time.Time {
+	bytes, err := repo.Blobs(ctx).Get(ctx, dgst)
+	if err != nil {
+		return time.Time{}
+	}
+
+	var cache struct {
+		Layers []struct {
+			Annotations struct {
+				CreatedAt time.Time `json:"createdAt"`
+			} `json:"annotations"`
+		} `json:"layers"`
+	}
+
+	var created time.Time
+	if err := json.Unmarshal(bytes, &cache); err == nil {
+		for _, layer := range cache.Layers {
+			if layer.Annotations.CreatedAt.After(created) {
+				created = layer.Annotations.CreatedAt
+			}
+		}
+	}
+	return created
+}
+
+func isManifest(mediaType string) bool {
+	switch mediaType {
+	case schema2.MediaTypeManifest, v1.MediaTypeImageManifest, manifestlist.MediaTypeManifestList, v1.MediaTypeImageIndex:
+		return true
+	default:
+		return false
+	}
+}
+
+func (repo *repository) manifest(ctx context.Context, dgst digest.Digest) (distribution.Manifest, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifests.Get(ctx, dgst)
+}
+
+func ListTags(log *util.Logger, location string) ([]Tag, error) {
+	named, err := reference.ParseNormalizedNamed(location)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, named, actionsPull)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Listing tags of", named)
+	tags, err := repo.listTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tags {
+		tag := &tags[i]
+		if tag.Digest != "" && tag.Created != nil {
+			continue
+		}
+
+		desc, err := repo.Tags(ctx).Get(ctx, tag.Name)
+		if err != nil {
+			return nil, err
+		}
+		tag.Digest = desc.Digest.String()
+
+		if manifest, err := repo.manifest(ctx, desc.Digest); err == nil {
+			tag.Created = knownTime(repo.created(ctx, manifest))
+		}
+	}
+
+	return tags, nil
+}
+
+// Deletes the given tags, and the manifest they refer to. The manifest should
+// not be referred to by any other tags.
+func DeleteTags(log *util.Logger, location string, dgst string, tags []string) error {
+	named, err := reference.ParseNormalizedNamed(location)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, named, actionsDelete)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		log.Debug("Deleting remote tag", named.Name()+":"+tag)
+		if err := repo.untag(ctx, tag); err != nil {
+			// Registries that do not support deleting tags delete them together
+			// with the manifest instead.
+			log.Debug(err)
+		}
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Deleting remote manifest", named.Name()+"@"+dgst)
+	err = manifests.Delete(ctx, digest.Digest(dgst))
+	if err != nil && !IsNotFound(err) {
+		return errors.Wrapf(err, "Could not delete %s@%s", named.Name(), dgst)
+	}
+
+	return nil
+}
+
+// Returns whether the error means that a manifest or tag does not exist.
+func IsNotFound(err error) bool {
+	var unexpected *distributionclient.UnexpectedHTTPResponseError
+	if errors.As(err, &unexpected) {
+		return unexpected.StatusCode == http.StatusNotFound
+	}
+
+	var errs errcode.Errors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		err = errs[0]
+	}
+
+	var code errcode.Error
+	return errors.As(err, &code) && code.Code == v2.ErrorCodeManifestUnknown
+}
+
+func parseMillis(value string) time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+// Returns the URL of the next page from a Link header, as used for
+// pagination by the registry API.
+func nextLink(current string, header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+
+	start, end := -1, -1
+	for i, c := range header {
+		if c == '<' {
+			start = i + 1
+		} else if c == '>' {
+			end = i
+			break
+		}
+	}
+
+	if start < 0 || end < start {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := base.Parse(header[start:end])
+	if err != nil {
+		return "", err
+	}
+
+	return next.String(), nil
+}
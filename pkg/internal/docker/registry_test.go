@@ -0,0 +1,266 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/distribution/reference"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+type testManifest struct {
+	mediaType string
+	payload   []byte
+}
+
+// An in-memory registry that implements the parts of the registry API that
+// kd uses. Registries on localhost are accessed over plain HTTP.
+type testRegistry struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	uploads   map[string][]byte
+	manifests map[string]testManifest
+	tags      map[string]map[string]string
+
+	// Manifests that were requested but do not exist.
+	missing []string
+}
+
+var testRegistryPath = regexp.MustCompile(`^/v2/(.+)/(blobs/uploads/.*|blobs/[^/]+|manifests/[^/]+|tags/list)$`)
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{
+		blobs:     map[string][]byte{},
+		uploads:   map[string][]byte{},
+		manifests: map[string]testManifest{},
+		tags:      map[string]map[string]string{},
+	}
+
+	reg.Server = httptest.NewServer(http.HandlerFunc(reg.serve))
+	t.Cleanup(reg.Close)
+	return reg
+}
+
+// Returns the host and port of the registry, for use in image references.
+func (reg *testRegistry) Host() string {
+	return strings.TrimPrefix(reg.URL, "http://")
+}
+
+func (reg *testRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.URL.Path == "/v2/" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	match := testRegistryPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	name, resource := match[1], match[2]
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case resource == "tags/list":
+		var tags []string
+		for tag := range reg.tags[name] {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		fmt.Fprintf(w, `{"name":%q,"tags":["%s"]}`, name, strings.Join(tags, `","`))
+
+	case strings.HasPrefix(resource, "blobs/uploads/"):
+		id := strings.TrimPrefix(resource, "blobs/uploads/")
+		location := "/v2/" + name + "/blobs/uploads/"
+
+		switch r.Method {
+		case http.MethodPost:
+			id = fmt.Sprint(len(reg.uploads) + 1)
+			reg.uploads[id] = nil
+			w.Header().Set("Location", location+id)
+			w.Header().Set("Range", "0-0")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPatch:
+			reg.uploads[id] = append(reg.uploads[id], body...)
+			w.Header().Set("Location", location+id)
+			w.Header().Set("Range", fmt.Sprintf("0-%d", len(reg.uploads[id])-1))
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			content := append(reg.uploads[id], body...)
+			dgst := digest.FromBytes(content)
+			if dgst.String() != r.URL.Query().Get("digest") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reg.blobs[dgst.String()] = content
+			delete(reg.uploads, id)
+			w.Header().Set("Location", "/v2/"+name+"/blobs/"+dgst.String())
+			w.Header().Set("Docker-Content-Digest", dgst.String())
+			w.WriteHeader(http.StatusCreated)
+		}
+
+	case strings.HasPrefix(resource, "blobs/"):
+		dgst := strings.TrimPrefix(resource, "blobs/")
+		content, ok := reg.blobs[dgst]
+		if !ok {
+			reg.notFound(w, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+
+	case strings.HasPrefix(resource, "manifests/"):
+		ref := strings.TrimPrefix(resource, "manifests/")
+		dgst := ref
+		if !strings.Contains(ref, ":") {
+			dgst = reg.tags[name][ref]
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			dgst := digest.FromBytes(body).String()
+			reg.manifests[dgst] = testManifest{r.Header.Get("Content-Type"), body}
+			if !strings.Contains(ref, ":") {
+				if reg.tags[name] == nil {
+					reg.tags[name] = map[string]string{}
+				}
+				reg.tags[name][ref] = dgst
+			}
+			w.Header().Set("Location", "/v2/"+name+"/manifests/"+dgst)
+			w.Header().Set("Docker-Content-Digest", dgst)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if strings.Contains(ref, ":") {
+				delete(reg.manifests, ref)
+			} else {
+				delete(reg.tags[name], ref)
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			m, ok := reg.manifests[dgst]
+			if !ok {
+				reg.missing = append(reg.missing, ref)
+				reg.notFound(w, "MANIFEST_UNKNOWN")
+				return
+			}
+			w.Header().Set("Content-Type", m.mediaType)
+			w.Header().Set("Content-Length", fmt.Sprint(len(m.payload)))
+			w.Header().Set("Docker-Content-Digest", dgst)
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				w.Write(m.payload)
+			}
+		}
+	}
+}
+
+func (reg *testRegistry) notFound(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, `{"errors":[{"code":%q,"message":"not found"}]}`, code)
+}
+
+// Pushes an image with an empty configuration to the registry.
+func (reg *testRegistry) pushImage(t *testing.T, location string) Image {
+	return reg.pushPlatformImage(t, location, "linux/amd64")
+}
+
+// Pushes an image index with an image for each of the given platforms.
+func (reg *testRegistry) pushIndex(t *testing.T, location string, platforms ...string) Image {
+	var descriptors []manifestlist.ManifestDescriptor
+
+	for _, platform := range platforms {
+		img := reg.pushPlatformImage(t, location, platform)
+		os, arch, _ := strings.Cut(platform, "/")
+		descriptors = append(descriptors, manifestlist.ManifestDescriptor{
+			Descriptor: img.Descriptor,
+			Platform:   manifestlist.PlatformSpec{OS: os, Architecture: arch},
+		})
+	}
+
+	index, err := manifestlist.FromDescriptorsWithMediaType(descriptors, v1.MediaTypeImageIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := reference.ParseNormalizedNamed(location)
+	img := Image{Named: reference.TrimNamed(ref), Manifest: index}
+	if err := TagImage(util.NewLogger("test"), img, location); err != nil {
+		t.Fatal(err)
+	}
+
+	_, payload, _ := index.Payload()
+	img.Descriptor = distribution.Descriptor{
+		MediaType: v1.MediaTypeImageIndex,
+		Size:      int64(len(payload)),
+		Digest:    digest.FromBytes(payload),
+	}
+
+	return img
+}
+
+func (reg *testRegistry) pushPlatformImage(t *testing.T, location string, platform string) Image {
+	ctx := context.Background()
+	ref, err := reference.ParseNormalizedNamed(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := openRepository(ctx, util.NewLogger("test"), ref, actionsPush)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os, arch, _ := strings.Cut(platform, "/")
+	config, err := repo.Blobs(ctx).Put(ctx, v1.MediaTypeImageConfig, []byte(fmt.Sprintf(`{"os":%q,"architecture":%q}`, os, arch)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.MediaType = v1.MediaTypeImageConfig
+
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 2, MediaType: v1.MediaTypeImageManifest},
+		Config:    config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := Image{Named: reference.TrimNamed(ref), Manifest: m}
+	if err := TagImage(util.NewLogger("test"), img, location); err != nil {
+		t.Fatal(err)
+	}
+
+	_, payload, _ := m.Payload()
+	img.Descriptor = distribution.Descriptor{
+		MediaType: v1.MediaTypeImageManifest,
+		Size:      int64(len(payload)),
+		Digest:    digest.FromBytes(payload),
+	}
+	return img
+}
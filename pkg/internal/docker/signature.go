@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// Signatures are stored in the same way as cosign stores them: as layers of
+// an OCI manifest tagged sha256-<digest>.sig, with the signed payload as the
+// layer content and the signature in an annotation.
+const (
+	mediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	annotationSignature    = "dev.cosignproject.cosign/signature"
+	signatureTagSuffix     = ".sig"
+)
+
+// A signed payload and its base64 encoded signature.
+type Signature struct {
+	Payload   []byte
+	Signature string
+}
+
+// Returns the signatures of an image, if any.
+func GetSignatures(log *util.Logger, img Image) ([]Signature, error) {
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, img.Named, actionsPull)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Retrieving signatures", img.Named.Name()+":"+signatureTag(img.Descriptor.Digest))
+	sigManifest, err := repo.signatureManifest(ctx, img.Descriptor.Digest)
+	if err != nil || sigManifest == nil {
+		return nil, err
+	}
+
+	var signatures []Signature
+	for _, layer := range sigManifest.Layers {
+		if layer.MediaType != mediaTypeSimpleSigning {
+			continue
+		}
+
+		payload, err := repo.Blobs(ctx).Get(ctx, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, Signature{
+			Payload:   payload,
+			Signature: layer.Annotations[annotationSignature],
+		})
+	}
+
+	return signatures, nil
+}
+
+// Adds a signature to an image. Existing signatures are kept.
+func AddSignature(log *util.Logger, img Image, sig Signature) error {
+	ctx := context.Background()
+	repo, err := openRepository(ctx, log, img.Named, actionsPush)
+	if err != nil {
+		return err
+	}
+
+	existing, err := repo.signatureManifest(ctx, img.Descriptor.Digest)
+	if err != nil {
+		return err
+	}
+
+	var layers []distribution.Descriptor
+	if existing != nil {
+		layers = existing.Layers
+	}
+
+	blobs := repo.Blobs(ctx)
+	layer, err := blobs.Put(ctx, mediaTypeSimpleSigning, sig.Payload)
+	if err != nil {
+		return err
+	}
+
+	layer.MediaType = mediaTypeSimpleSigning
+	layer.Annotations = map[string]string{annotationSignature: sig.Signature}
+	layers = append(layers, layer)
+
+	config, err := blobs.Put(ctx, v1.MediaTypeImageConfig, signatureConfig(layers))
+	if err != nil {
+		return err
+	}
+	config.MediaType = v1.MediaTypeImageConfig
+
+	sigManifest, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 2, MediaType: v1.MediaTypeImageManifest},
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag := signatureTag(img.Descriptor.Digest)
+	log.Debug("Storing signatures", img.Named.Name()+":"+tag)
+	_, err = manifests.Put(ctx, sigManifest, distribution.WithTag(tag))
+	return err
+}
+
+func (repo *repository) signatureManifest(ctx context.Context, dgst digest.Digest) (*ocischema.DeserializedManifest, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := manifests.Get(ctx, "", distribution.WithTag(signatureTag(dgst)))
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sigManifest, ok := m.(*ocischema.DeserializedManifest)
+	if !ok {
+		return nil, nil
+	}
+
+	return sigManifest, nil
+}
+
+func signatureTag(dgst digest.Digest) string {
+	return strings.Replace(dgst.String(), ":", "-", 1) + signatureTagSuffix
+}
+
+// Returns an image configuration that lists the signature layers, like cosign
+// does.
+func signatureConfig(layers []distribution.Descriptor) []byte {
+	diffIDs := make([]string, len(layers))
+	for i, layer := range layers {
+		diffIDs[i] = layer.Digest.String()
+	}
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"architecture": "",
+		"os":           "",
+		"config":       map[string]interface{}{},
+		"rootfs": map[string]interface{}{
+			"type":     "layers",
+			"diff_ids": diffIDs,
+		},
+	})
+
+	return config
+}
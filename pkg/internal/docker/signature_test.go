@@ -0,0 +1,33 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestSignatures(t *testing.T) {
+	log := util.NewLogger("test")
+	reg := newTestRegistry(t)
+	img := reg.pushImage(t, reg.Host()+"/foo:latest")
+
+	signatures, err := GetSignatures(log, img)
+	assert.Nil(t, err)
+	assert.Empty(t, signatures)
+
+	err = AddSignature(log, img, Signature{Payload: []byte("one"), Signature: "c2lnMQ=="})
+	assert.Nil(t, err)
+
+	err = AddSignature(log, img, Signature{Payload: []byte("two"), Signature: "c2lnMg=="})
+	assert.Nil(t, err)
+
+	signatures, err = GetSignatures(log, img)
+	assert.Nil(t, err)
+	assert.Equal(t, []Signature{
+		{Payload: []byte("one"), Signature: "c2lnMQ=="},
+		{Payload: []byte("two"), Signature: "c2lnMg=="},
+	}, signatures)
+
+	assert.Contains(t, reg.tags["foo"], "sha256-"+img.Descriptor.Digest.Encoded()+".sig")
+}
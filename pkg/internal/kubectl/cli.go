@@ -1,10 +1,16 @@
 package kubectl
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/voormedia/kd/pkg/config"
 	"github.com/voormedia/kd/pkg/util"
+	core "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/version"
 )
@@ -17,6 +23,30 @@ func ApplyFromStdin(log *util.Logger, target *config.ResolvedTarget, input []byt
 		"apply", "-f", "-")
 }
 
+// Creates the resources in the input, without writing them to the output.
+func CreateFromStdin(log *util.Logger, target *config.ResolvedTarget, input []byte) error {
+	_, err := util.CaptureWithInput(log, input,
+		"kubectl",
+		"--context", target.Context,
+		"--namespace", target.Namespace,
+		"create", "-f", "-")
+
+	return err
+}
+
+// Deletes a job and its pods.
+func DeleteJob(log *util.Logger, target *config.ResolvedTarget, name string) error {
+	_, err := util.Capture(log,
+		"kubectl",
+		"--context", target.Context,
+		"--namespace", target.Namespace,
+		"delete", "job", name,
+		"--ignore-not-found",
+		"--wait=false")
+
+	return err
+}
+
 func GetGCEIngresses(log *util.Logger, target *config.ResolvedTarget) ([]*networking.Ingress, error) {
 	bytes, err := util.Capture(log,
 		"kubectl",
@@ -44,6 +74,127 @@ func GetGCEIngresses(log *util.Logger, target *config.ResolvedTarget) ([]*networ
 	return ingresses, nil
 }
 
+// Returns the images of all containers in the pods of the target, both as
+// specified and as resolved to a digest by the container runtime.
+func GetPodImages(log *util.Logger, target *config.ResolvedTarget) ([]string, error) {
+	pods, err := GetPods(log, target, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, pod := range pods {
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			images = append(images, container.Image)
+		}
+
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if status.ImageID != "" {
+				images = append(images, status.ImageID)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// Returns the pods of the target that match the label selector, or all pods
+// if the selector is empty.
+func GetPods(log *util.Logger, target *config.ResolvedTarget, selector string) ([]core.Pod, error) {
+	args := []string{
+		"--context", target.Context,
+		"--namespace", target.Namespace,
+		"get", "pods",
+		"--output", "json",
+	}
+
+	if selector != "" {
+		args = append(args, "--selector", selector)
+	}
+
+	bytes, err := util.Capture(log, "kubectl", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var list core.PodList
+	if err := json.Unmarshal(bytes, &list); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// Returns a resource of the target as JSON, or nil if it does not exist.
+func GetResource(log *util.Logger, target *config.ResolvedTarget, kind string, name string) ([]byte, error) {
+	bytes, err := util.Capture(log,
+		"kubectl",
+		"--context", target.Context,
+		"--namespace", target.Namespace,
+		"get", kind, name,
+		"--ignore-not-found",
+		"--output", "json")
+
+	if err != nil || len(bytes) == 0 {
+		return nil, err
+	}
+
+	return bytes, nil
+}
+
+type LogOptions struct {
+	// Only return logs newer than this duration. Zero returns all logs.
+	Since time.Duration
+
+	// Keep streaming logs until the container stops.
+	Follow bool
+}
+
+// Streams the logs of a container of a pod. The output of kubectl, including
+// any errors, is written to the returned reader. The reader returns an error
+// if kubectl fails, and stops when the context is cancelled.
+func StreamLogs(ctx context.Context, log *util.Logger, target *config.ResolvedTarget, pod string, container string, options LogOptions) io.ReadCloser {
+	args := []string{
+		"--context", target.Context,
+		"--namespace", target.Namespace,
+		"logs", pod,
+		"--container", container,
+	}
+
+	if options.Since > 0 {
+		args = append(args, "--since", options.Since.String())
+	}
+
+	if options.Follow {
+		args = append(args, "--follow")
+	}
+
+	log.Debug("Executing:", "kubectl", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	go func() {
+		writer.CloseWithError(cmd.Run())
+	}()
+
+	return reader
+}
+
+// Executes a command in a container of a pod, with the standard input and
+// output of kd. A TTY is allocated if requested.
+func Exec(log *util.Logger, target *config.ResolvedTarget, pod string, container string, tty bool, command ...string) error {
+	args := []string{"exec", pod, "--container", container, "--stdin"}
+	if tty {
+		args = append(args, "--tty")
+	}
+
+	args = append(append(args, "--"), command...)
+	return RunForTarget(log, target, args...)
+}
+
 func RunForTarget(log *util.Logger, target *config.ResolvedTarget, args ...string) error {
 	args = append([]string{
 		"--context", target.Context,
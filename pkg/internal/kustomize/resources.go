@@ -14,14 +14,7 @@ import (
 )
 
 func GetResources(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, digest string) ([]byte, error) {
-	fSys := filesys.MakeFsOnDisk()
-
-	kust := krusty.MakeKustomizer(
-		krusty.MakeDefaultOptions(),
-	)
-
-	log.Debug("Running kustomize build for", filepath.Join(app.Path, target.Path))
-	res, err := kust.Run(fSys, filepath.Join(app.Path, target.Path))
+	res, err := build(log, app, target)
 	if err != nil {
 		return nil, err
 	}
@@ -51,12 +44,24 @@ func GetResources(log *util.Logger, app *config.ResolvedApp, target *config.Reso
 		}
 	}
 
-	url := app.RepositoryWithDigest(digest)
+	url := app.TargetRepository(target) + "@" + digest
 	buf := bytes.NewBuffer(bytes.Replace(yml, []byte(" image: "+app.Name), []byte(" image: "+url), -1))
 
 	return buf.Bytes(), nil
 }
 
+// Runs kustomize build for the configuration of the app for the target.
+func build(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget) (resmap.ResMap, error) {
+	fSys := filesys.MakeFsOnDisk()
+
+	kust := krusty.MakeKustomizer(
+		krusty.MakeDefaultOptions(),
+	)
+
+	log.Debug("Running kustomize build for", filepath.Join(app.Path, target.Path))
+	return kust.Run(fSys, filepath.Join(app.Path, target.Path))
+}
+
 func namespace(target *config.ResolvedTarget) []byte {
 	var out bytes.Buffer
 	namespaceTmpl.Execute(&out, target)
@@ -0,0 +1,179 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// A resource that runs pods, with the labels that select its pods.
+type Workload struct {
+	Kind     string
+	Name     string
+	Selector map[string]string
+}
+
+var workloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob"}
+
+// Returns the workloads in the configuration of the app for the target.
+func GetWorkloads(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget) ([]Workload, error) {
+	res, err := build(log, app, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var workloads []Workload
+	for _, r := range res.Resources() {
+		if !slices.Contains(workloadKinds, r.GetKind()) {
+			continue
+		}
+
+		bytes, err := r.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		selector, err := workloadSelector(bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(selector) == 0 {
+			log.Debug("Skipping", r.GetKind(), r.GetName(), "without pod labels")
+			continue
+		}
+
+		workloads = append(workloads, Workload{
+			Kind:     r.GetKind(),
+			Name:     r.GetName(),
+			Selector: selector,
+		})
+	}
+
+	return workloads, nil
+}
+
+// Returns the deployments in the configuration of the app for the target, with
+// the image of the app set to the given digest.
+func GetDeployments(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, digest string) ([]apps.Deployment, error) {
+	yml, err := GetResources(log, app, target, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resmap.NewFactory(provider.NewDepProvider().GetResourceFactory()).NewResMapFromBytes(yml)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []apps.Deployment
+	for _, r := range res.Resources() {
+		if r.GetKind() != "Deployment" {
+			continue
+		}
+
+		bytes, err := r.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		var deployment apps.Deployment
+		if err := json.Unmarshal(bytes, &deployment); err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
+}
+
+// Returns the selector of a workload as accepted by kubectl, for example
+// "app=web,tier=frontend".
+func (workload Workload) LabelSelector() string {
+	labels := make([]string, 0, len(workload.Selector))
+	for key, value := range workload.Selector {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+// Reports whether a pod with the given labels is selected by the workload.
+func (workload Workload) Matches(labels map[string]string) bool {
+	for key, value := range workload.Selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a selector as accepted by kubectl that matches the pods of all given
+// workloads, and possibly other pods: the labels that all workloads select on.
+// Returns an empty selector if they have no labels in common.
+func CommonSelector(workloads []Workload) string {
+	if len(workloads) == 0 {
+		return ""
+	}
+
+	common := Workload{Selector: map[string]string{}}
+	for key, value := range workloads[0].Selector {
+		common.Selector[key] = value
+	}
+
+	for _, workload := range workloads[1:] {
+		for key, value := range common.Selector {
+			if workload.Selector[key] != value {
+				delete(common.Selector, key)
+			}
+		}
+	}
+
+	return common.LabelSelector()
+}
+
+type podTemplate struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+// Returns the labels that select the pods of a workload. Workloads without an
+// explicit selector, such as jobs, are selected by the labels of their pod
+// template.
+func workloadSelector(bytes []byte) (map[string]string, error) {
+	var workload struct {
+		Spec struct {
+			Selector struct {
+				MatchLabels map[string]string `json:"matchLabels"`
+			} `json:"selector"`
+			Template    podTemplate `json:"template"`
+			JobTemplate struct {
+				Spec struct {
+					Template podTemplate `json:"template"`
+				} `json:"spec"`
+			} `json:"jobTemplate"`
+		} `json:"spec"`
+	}
+
+	if err := json.Unmarshal(bytes, &workload); err != nil {
+		return nil, err
+	}
+
+	spec := workload.Spec
+	switch {
+	case len(spec.Selector.MatchLabels) > 0:
+		return spec.Selector.MatchLabels, nil
+	case len(spec.Template.Metadata.Labels) > 0:
+		return spec.Template.Metadata.Labels, nil
+	default:
+		return spec.JobTemplate.Spec.Template.Metadata.Labels, nil
+	}
+}
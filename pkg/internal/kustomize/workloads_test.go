@@ -0,0 +1,83 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+func TestGetWorkloads(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "production")
+	assert.Nil(t, os.Mkdir(dir, 0755))
+
+	files := map[string]string{
+		"kustomization.yaml": "resources:\n- web.yaml\n- worker.yaml\ncommonLabels:\n  env: production\n",
+		"web.yaml":           "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  selector:\n    matchLabels:\n      app: web\n  template:\n    metadata:\n      labels:\n        app: web\n    spec:\n      containers:\n      - name: web\n        image: my-app\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  selector:\n    app: web\n",
+		"worker.yaml":        "apiVersion: batch/v1\nkind: CronJob\nmetadata:\n  name: worker\nspec:\n  schedule: '@daily'\n  jobTemplate:\n    spec:\n      template:\n        metadata:\n          labels:\n            app: worker\n        spec:\n          containers:\n          - name: worker\n            image: my-app\n",
+	}
+
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	app := &config.ResolvedApp{App: config.App{Name: "my-app", Path: root}}
+	target := &config.ResolvedTarget{Target: config.Target{Name: "production", Path: "production"}}
+
+	workloads, err := GetWorkloads(util.NewLogger("test"), app, target)
+	assert.Nil(t, err)
+	assert.Equal(t, []Workload{
+		{Kind: "Deployment", Name: "web", Selector: map[string]string{"app": "web", "env": "production"}},
+		{Kind: "CronJob", Name: "worker", Selector: map[string]string{"app": "worker", "env": "production"}},
+	}, workloads)
+}
+
+func TestWorkloadSelectorDeployment(t *testing.T) {
+	selector, err := workloadSelector([]byte(`{
+		"kind": "Deployment",
+		"spec": {
+			"selector": {"matchLabels": {"app": "web"}},
+			"template": {"metadata": {"labels": {"app": "web", "version": "1"}}}
+		}
+	}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"app": "web"}, selector)
+}
+
+func TestWorkloadSelectorCronJob(t *testing.T) {
+	selector, err := workloadSelector([]byte(`{
+		"kind": "CronJob",
+		"spec": {
+			"jobTemplate": {"spec": {"template": {"metadata": {"labels": {"app": "cleanup"}}}}}
+		}
+	}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"app": "cleanup"}, selector)
+}
+
+func TestLabelSelector(t *testing.T) {
+	workload := Workload{Selector: map[string]string{"tier": "frontend", "app": "web"}}
+	assert.Equal(t, "app=web,tier=frontend", workload.LabelSelector())
+}
+
+func TestCommonSelector(t *testing.T) {
+	web := Workload{Selector: map[string]string{"app.kubernetes.io/name": "my-app", "component": "web"}}
+	worker := Workload{Selector: map[string]string{"app.kubernetes.io/name": "my-app", "component": "worker"}}
+	cleanup := Workload{Selector: map[string]string{"job": "cleanup"}}
+
+	assert.Equal(t, "app.kubernetes.io/name=my-app", CommonSelector([]Workload{web, worker}))
+	assert.Equal(t, "", CommonSelector([]Workload{web, worker, cleanup}))
+	assert.Equal(t, "app.kubernetes.io/name=my-app,component=web", CommonSelector([]Workload{web}))
+}
+
+func TestMatches(t *testing.T) {
+	web := Workload{Selector: map[string]string{"app": "web"}}
+	assert.True(t, web.Matches(map[string]string{"app": "web", "pod-template-hash": "abc"}))
+	assert.False(t, web.Matches(map[string]string{"app": "worker"}))
+}
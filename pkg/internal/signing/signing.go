@@ -0,0 +1,166 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+const signatureType = "cosign container image signature"
+
+// The simple signing payload that is signed, as used by cosign.
+type payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// Reads an unencrypted PEM encoded ECDSA private key from a file.
+func ReadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read signing key")
+	}
+
+	return ParsePrivateKey(bytes)
+}
+
+// Parses an unencrypted PEM encoded ECDSA private key, either in PKCS #8 or
+// SEC 1 form.
+func ParsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Signing key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if ecdsaKey, ok := key.(*ecdsa.PrivateKey); ok {
+			return ecdsaKey, nil
+		}
+		return nil, fmt.Errorf("Signing key must be an ECDSA key")
+	default:
+		return nil, fmt.Errorf("Signing key of type '%s' is not supported, use an unencrypted ECDSA key", block.Type)
+	}
+}
+
+// Reads PEM encoded ECDSA public keys from files.
+func ReadPublicKeys(paths []string) ([]*ecdsa.PublicKey, error) {
+	var keys []*ecdsa.PublicKey
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not read public key")
+		}
+
+		key, err := ParsePublicKey(bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not parse public key %s", path)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("Public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if ecdsaKey, ok := key.(*ecdsa.PublicKey); ok {
+		return ecdsaKey, nil
+	}
+	return nil, fmt.Errorf("Public key must be an ECDSA key")
+}
+
+// Signs the digest of an image in the given repository.
+func Sign(key *ecdsa.PrivateKey, repository string, digest string) (docker.Signature, error) {
+	var p payload
+	p.Critical.Identity.DockerReference = repository
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = signatureType
+
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return docker.Signature{}, err
+	}
+
+	hash := sha256.Sum256(bytes)
+	sig, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		return docker.Signature{}, err
+	}
+
+	return docker.Signature{
+		Payload:   bytes,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// Returns true if any of the signatures of the digest of an image was made
+// with one of the given keys.
+func Verify(keys []*ecdsa.PublicKey, digest string, signatures []docker.Signature) bool {
+	for _, sig := range signatures {
+		if verify(keys, digest, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+func verify(keys []*ecdsa.PublicKey, digest string, sig docker.Signature) bool {
+	var p payload
+	if err := json.Unmarshal(sig.Payload, &p); err != nil {
+		return false
+	}
+
+	if p.Critical.Type != signatureType || p.Critical.Image.DockerManifestDigest != digest {
+		return false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256(sig.Payload)
+	for _, key := range keys {
+		if ecdsa.VerifyASN1(key, hash[:], raw) {
+			return true
+		}
+	}
+
+	return false
+}
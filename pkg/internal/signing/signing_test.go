@@ -0,0 +1,82 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key := generateKey(t)
+
+	sig, err := Sign(key, "registry.example.com/foo", testDigest)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"critical": {
+			"identity": {"docker-reference": "registry.example.com/foo"},
+			"image": {"docker-manifest-digest": "`+testDigest+`"},
+			"type": "cosign container image signature"
+		},
+		"optional": null
+	}`, string(sig.Payload))
+
+	keys := []*ecdsa.PublicKey{&generateKey(t).PublicKey, &key.PublicKey}
+	assert.True(t, Verify(keys, testDigest, []docker.Signature{sig}))
+}
+
+func TestVerifyOtherKey(t *testing.T) {
+	sig, err := Sign(generateKey(t), "registry.example.com/foo", testDigest)
+	assert.Nil(t, err)
+
+	keys := []*ecdsa.PublicKey{&generateKey(t).PublicKey}
+	assert.False(t, Verify(keys, testDigest, []docker.Signature{sig}))
+}
+
+func TestVerifyOtherDigest(t *testing.T) {
+	key := generateKey(t)
+	sig, err := Sign(key, "registry.example.com/foo", testDigest)
+	assert.Nil(t, err)
+
+	keys := []*ecdsa.PublicKey{&key.PublicKey}
+	assert.False(t, Verify(keys, "sha256:fedcba", []docker.Signature{sig}))
+}
+
+func TestParseKeys(t *testing.T) {
+	key := generateKey(t)
+
+	sec1, _ := x509.MarshalECPrivateKey(key)
+	parsed, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}))
+	assert.Nil(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	parsed, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	assert.Nil(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	pkix, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	public, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	assert.Nil(t, err)
+	assert.True(t, key.PublicKey.Equal(public))
+}
+
+func TestParseEncryptedKey(t *testing.T) {
+	_, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: []byte{}}))
+	assert.Equal(t, "Signing key of type 'ENCRYPTED SIGSTORE PRIVATE KEY' is not supported, use an unencrypted ECDSA key", err.Error())
+}
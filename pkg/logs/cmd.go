@@ -0,0 +1,282 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/kubectl"
+	"github.com/voormedia/kd/pkg/internal/kustomize"
+	"github.com/voormedia/kd/pkg/util"
+	core "k8s.io/api/core/v1"
+)
+
+// How often to look for new pods while following logs.
+const pollInterval = 2 * time.Second
+
+type Options struct {
+	// Only show logs newer than this duration. Zero shows all logs.
+	Since time.Duration
+
+	// Only show logs of containers with this name. Empty shows all containers.
+	Container string
+
+	// Only show log lines that match this expression, if any.
+	Grep *regexp.Regexp
+
+	// Keep streaming logs, including those of pods that start later.
+	Follow bool
+}
+
+// Streams the logs of all pods of the workloads of the app in the target. The
+// pods are found with the selectors of the workloads in the configuration of
+// the app. When following, new pods are picked up as they start, for example
+// during a rollout, until the context is cancelled. Errors while looking for
+// new pods are reported and retried, without interrupting the logs that are
+// already streaming.
+func Run(ctx context.Context, log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, options Options) error {
+	workloads, err := kustomize.GetWorkloads(log, app, target)
+	if err != nil {
+		return err
+	}
+
+	if len(workloads) == 0 {
+		return fmt.Errorf("No workloads of '%s' found in configuration for '%s'", app.Name, target.Name)
+	}
+
+	// Retrieve the pods of all workloads at once, and select the pods of each
+	// workload from them.
+	selector := kustomize.CommonSelector(workloads)
+
+	out := &printer{out: os.Stdout, grep: options.Grep}
+	streams := map[string]bool{}
+	colors := map[string]*color.Color{}
+
+	var wg sync.WaitGroup
+	waiting := false
+	failing := false
+
+	for {
+		pods, err := kubectl.GetPods(log, target, selector)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Interrupted while retrieving pods.
+			wg.Wait()
+			return nil
+		case err != nil && !options.Follow:
+			return err
+		case err != nil:
+			if !failing {
+				log.Warn("Could not retrieve pods of", app.Name+", retrying:", err)
+			}
+			failing = true
+		default:
+			if failing {
+				log.Note("Retrieved pods of", app.Name, "again")
+			}
+			failing = false
+		}
+
+		found := false
+		for _, pod := range pods {
+			if !matchesAny(workloads, pod) {
+				continue
+			}
+
+			for _, status := range startedContainers(pod) {
+				if options.Container != "" && status.Name != options.Container {
+					continue
+				}
+
+				found = true
+				key := fmt.Sprintf("%s/%s/%d", pod.Name, status.Name, status.RestartCount)
+				if streams[key] {
+					continue
+				}
+				streams[key] = true
+
+				if colors[pod.Name] == nil {
+					colors[pod.Name] = palette[len(colors)%len(palette)]
+				}
+
+				prefix := colors[pod.Name].Sprint(pod.Name + "/" + status.Name)
+				reader := kubectl.StreamLogs(ctx, log, target, pod.Name, status.Name, kubectl.LogOptions{
+					Since:  options.Since,
+					Follow: options.Follow,
+				})
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := out.stream(prefix, reader); err != nil && ctx.Err() == nil {
+						log.Debug("Stopped streaming logs of", pod.Name+"/"+status.Name+":", err)
+					}
+				}()
+			}
+		}
+
+		if !options.Follow {
+			if !found {
+				log.Note("No running pods of", app.Name, "found in", target.Name)
+			}
+			break
+		}
+
+		if !failing {
+			if !found && !waiting {
+				log.Note("Waiting for pods of", app.Name, "in", target.Name)
+			}
+			waiting = !found
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-time.After(pollInterval):
+		}
+	}
+
+	wg.Wait()
+	return nil
+}
+
+func matchesAny(workloads []kustomize.Workload, pod core.Pod) bool {
+	for _, workload := range workloads {
+		if workload.Matches(pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+var palette = []*color.Color{
+	color.New(color.FgCyan),
+	color.New(color.FgGreen),
+	color.New(color.FgMagenta),
+	color.New(color.FgYellow),
+	color.New(color.FgBlue),
+	color.New(color.FgHiCyan),
+	color.New(color.FgHiGreen),
+	color.New(color.FgHiMagenta),
+}
+
+// Returns the containers of a pod that have started and therefore have logs,
+// including init containers.
+func startedContainers(pod core.Pod) []core.ContainerStatus {
+	var started []core.ContainerStatus
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.State.Running != nil || status.State.Terminated != nil {
+			started = append(started, status)
+		}
+	}
+	return started
+}
+
+// Prints lines of multiple log streams, each with their prefix.
+type printer struct {
+	mu   sync.Mutex
+	out  io.Writer
+	grep *regexp.Regexp
+}
+
+func (p *printer) stream(prefix string, reader io.ReadCloser) error {
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p.print(prefix, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (p *printer) print(prefix string, line string) {
+	if p.grep != nil && !p.grep.MatchString(line) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, l := range strings.Split(formatLine(line), "\n") {
+		fmt.Fprintln(p.out, prefix, l)
+	}
+}
+
+// Fields of structured log entries that are not shown, because they are
+// noisy or only meaningful to Google Cloud Logging.
+var hiddenFields = []string{"message", "severity", "time", "timestamp"}
+
+const googleFieldPrefix = "logging.googleapis.com/"
+
+// Formats a structured log line in the JSON format of Google Cloud Logging as
+// its severity and message, followed by any other fields. Other lines are
+// returned as they are.
+func formatLine(line string) string {
+	if !strings.HasPrefix(line, "{") {
+		return line
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return line
+	}
+
+	message, ok := entry["message"].(string)
+	if !ok {
+		return line
+	}
+
+	severity, _ := entry["severity"].(string)
+
+	for _, field := range hiddenFields {
+		delete(entry, field)
+	}
+
+	for field := range entry {
+		if strings.HasPrefix(field, googleFieldPrefix) {
+			delete(entry, field)
+		}
+	}
+
+	formatted := message
+	if severity != "" {
+		formatted = severityColor(severity).Sprint(strings.ToUpper(severity)) + " " + message
+	}
+
+	if len(entry) > 0 {
+		// Fields are encoded in sorted order.
+		var fields bytes.Buffer
+		enc := json.NewEncoder(&fields)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(entry); err == nil {
+			formatted += " " + strings.TrimSuffix(fields.String(), "\n")
+		}
+	}
+
+	return formatted
+}
+
+func severityColor(severity string) *color.Color {
+	switch strings.ToUpper(severity) {
+	case "ERROR", "CRITICAL", "ALERT", "EMERGENCY":
+		return color.New(color.Bold, color.FgRed)
+	case "WARNING":
+		return color.New(color.Bold, color.FgYellow)
+	case "DEBUG":
+		return color.New(color.FgHiBlack)
+	default:
+		return color.New(color.Bold)
+	}
+}
@@ -0,0 +1,38 @@
+package logs
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	color.NoColor = true
+}
+
+func TestFormatLinePlain(t *testing.T) {
+	assert.Equal(t, "Listening on port 8080", formatLine("Listening on port 8080"))
+	assert.Equal(t, "{not json", formatLine("{not json"))
+	assert.Equal(t, `{"msg":"other format"}`, formatLine(`{"msg":"other format"}`))
+}
+
+func TestFormatLineStructured(t *testing.T) {
+	line := `{"severity":"error","message":"Request failed","time":"2024-01-01T00:00:00Z","logging.googleapis.com/trace":"abc","status":500,"path":"/a?b=1&c=2"}`
+	assert.Equal(t, `ERROR Request failed {"path":"/a?b=1&c=2","status":500}`, formatLine(line))
+
+	assert.Equal(t, "Started", formatLine(`{"message":"Started"}`))
+}
+
+func TestPrinterGrepAndPrefix(t *testing.T) {
+	var out bytes.Buffer
+	p := &printer{out: &out, grep: regexp.MustCompile("fail")}
+
+	p.print("web-1/app", "request ok")
+	p.print("web-1/app", "request failed")
+	p.print("web-1/app", `{"severity":"ERROR","message":"failed\nat main.go:12"}`)
+
+	assert.Equal(t, "web-1/app request failed\nweb-1/app ERROR failed\nweb-1/app at main.go:12\n", out.String())
+}
@@ -0,0 +1,62 @@
+package promote
+
+import (
+	"fmt"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/deploy"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/util"
+)
+
+// The image that was deployed to the destination target before and after
+// promotion. Before is empty if nothing was deployed yet.
+type Result struct {
+	Before docker.ImageDetails
+	After  docker.ImageDetails
+}
+
+// Deploys the image that is deployed to one target to another target. The
+// image is deployed by digest, so that it cannot change in between.
+func Run(log *util.Logger, app *config.ResolvedApp, from *config.ResolvedTarget, to *config.ResolvedTarget, clearCDNCaches bool) (Result, error) {
+	var result Result
+
+	if app.SkipBuild {
+		return result, fmt.Errorf("Application '%s' has no image to promote", app.Name)
+	}
+
+	if from.Name == to.Name {
+		return result, fmt.Errorf("Cannot promote from target '%s' to itself", from.Name)
+	}
+
+	log.Note("Retrieving image", app.Name+":"+from.Name)
+	img, err := docker.GetImage(log, app.RepositoryWithTag(from.Name))
+	if docker.IsNotFound(err) {
+		return result, fmt.Errorf("No image of '%s' is deployed to '%s'", app.Name, from.Name)
+	} else if err != nil {
+		return result, err
+	}
+
+	result.After, err = docker.DescribeImage(log, img)
+	if err != nil {
+		return result, err
+	}
+
+	log.Note("Retrieving image", app.Name+":"+to.Name)
+	current, err := docker.GetImage(log, app.RepositoryWithTag(to.Name))
+	if err == nil {
+		result.Before, err = docker.DescribeImage(log, current)
+	}
+	if err != nil && !docker.IsNotFound(err) {
+		return result, err
+	}
+
+	promoted := *app
+	promoted.Digest = result.After.Digest
+
+	if err := deploy.Run(log, &promoted, to, clearCDNCaches); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
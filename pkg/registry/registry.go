@@ -0,0 +1,12 @@
+package registry
+
+import (
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+)
+
+// Configures how kd authenticates with the registries in the 'registries'
+// section of the configuration.
+func Configure(conf *config.Config) {
+	docker.ConfigureRegistries(conf.Registries)
+}
@@ -0,0 +1,301 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/internal/kubectl"
+	"github.com/voormedia/kd/pkg/internal/kustomize"
+	"github.com/voormedia/kd/pkg/util"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// How often to check the status of the job.
+const pollInterval = time.Second
+
+// How long to wait for the container of the job to start.
+const startTimeout = 10 * time.Minute
+
+const labelManagedBy = "app.kubernetes.io/managed-by"
+
+// Label with the name of the deployment that a job is derived from. It is the
+// only label of the pod of the job.
+const labelRun = "kd.voormedia.com/run"
+
+// Reasons why a container is waiting that it will not recover from by itself.
+var failedReasons = []string{"ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError"}
+
+var errInterrupted = errors.New("Interrupted")
+
+type Options struct {
+	// Keep the job and its pod after it has finished.
+	Keep bool
+}
+
+// An error that the command of a job failed with an exit code.
+type ExitError struct {
+	Job  string
+	Code int
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("Job %s failed with exit code %d", err.Job, err.Code)
+}
+
+func (err *ExitError) ExitCode() int {
+	return err.Code
+}
+
+// Runs a command in a job that is derived from the deployment of the app in
+// the target. The job uses the image that is deployed to the target, and the
+// environment, volumes and service account of the deployment. The logs of the
+// job are written to the output. If the command fails, the error is an
+// *ExitError with its exit code.
+func Run(ctx context.Context, log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget, options Options, command []string) error {
+	digest := ""
+	if !app.SkipBuild {
+		log.Note("Retrieving image", app.Name+":"+target.Name)
+		img, err := docker.GetImage(log, app.RepositoryWithTag(target.Name))
+		if docker.IsNotFound(err) {
+			return fmt.Errorf("No image of '%s' is deployed to '%s'", app.Name, target.Name)
+		} else if err != nil {
+			return err
+		}
+		digest = img.Descriptor.Digest.String()
+	}
+
+	deployments, err := kustomize.GetDeployments(log, app, target, digest)
+	if err != nil {
+		return err
+	}
+
+	image := ""
+	if digest != "" {
+		image = app.TargetRepository(target) + "@" + digest
+	}
+
+	job := newJob(deployments, image, command, time.Now())
+	if job == nil {
+		return fmt.Errorf("No deployment of '%s' found in configuration for '%s'", app.Name, target.Name)
+	}
+
+	input, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	log.Note("Creating job", job.Name)
+	if err := kubectl.CreateFromStdin(log, target, input); err != nil {
+		return err
+	}
+
+	if !options.Keep {
+		defer func() {
+			log.Note("Deleting job", job.Name)
+			if err := kubectl.DeleteJob(log, target, job.Name); err != nil {
+				log.Warn("Could not delete job", job.Name+":", err)
+			}
+		}()
+	}
+
+	container := job.Spec.Template.Spec.Containers[0].Name
+
+	pod, err := waitForStart(ctx, log, target, job.Name, container)
+	if err != nil {
+		return err
+	}
+
+	reader := kubectl.StreamLogs(ctx, log, target, pod, container, kubectl.LogOptions{Follow: true})
+	if _, err := io.Copy(os.Stdout, reader); err != nil && ctx.Err() == nil {
+		log.Debug("Stopped streaming logs of", pod+":", err)
+	}
+
+	code, err := waitForExit(ctx, log, target, job.Name, container)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &ExitError{Job: job.Name, Code: code}
+	}
+
+	log.Success("Job", job.Name, "completed")
+	return nil
+}
+
+// Returns a job that runs the command in the app container of the first
+// deployment that runs the image, or nil if there is none. Other containers
+// are left out, so that the job finishes when the command does; sidecars that
+// are declared as init containers are kept. Probes are removed. The labels of
+// the deployment are replaced by a single kd label, so that the pod is not
+// selected by any service and does not receive traffic.
+func newJob(deployments []apps.Deployment, image string, command []string, now time.Time) *batch.Job {
+	for _, deployment := range deployments {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if image != "" && container.Image != image {
+				continue
+			}
+
+			template := deployment.Spec.Template.DeepCopy()
+			template.Labels = map[string]string{labelRun: deployment.Name}
+
+			container.Command = command
+			container.Args = nil
+			container.LivenessProbe = nil
+			container.ReadinessProbe = nil
+			container.StartupProbe = nil
+
+			template.Spec.Containers = []core.Container{container}
+			template.Spec.RestartPolicy = core.RestartPolicyNever
+
+			backoffLimit := int32(0)
+			return &batch.Job{
+				TypeMeta: meta.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+				ObjectMeta: meta.ObjectMeta{
+					Name:   jobName(deployment.Name, now),
+					Labels: map[string]string{labelManagedBy: "kd", labelRun: deployment.Name},
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template:     *template,
+				},
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns a unique name for a job of a deployment that fits in a DNS label.
+func jobName(deployment string, now time.Time) string {
+	suffix := "-run-" + strconv.FormatInt(now.Unix(), 36)
+	if length := 63 - len(suffix); len(deployment) > length {
+		deployment = strings.TrimRight(deployment[:length], "-")
+	}
+	return deployment + suffix
+}
+
+// Waits until the container of the pod of the job has started, and returns
+// the name of the pod. Reports when the pod cannot be scheduled, and fails if
+// the container has not started within the start timeout.
+func waitForStart(ctx context.Context, log *util.Logger, target *config.ResolvedTarget, job string, container string) (string, error) {
+	log.Note("Waiting for job", job, "to start")
+	deadline := time.Now().Add(startTimeout)
+	unschedulable := ""
+	for {
+		pods, err := kubectl.GetPods(log, target, "job-name="+job)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", errInterrupted
+			}
+			return "", err
+		}
+
+		for _, pod := range pods {
+			if pod.Status.Phase == core.PodFailed && len(pod.Status.ContainerStatuses) == 0 {
+				return "", fmt.Errorf("Job %s failed to start: %s", job, pod.Status.Message)
+			}
+
+			if message, ok := schedulingFailure(pod); ok {
+				if message != unschedulable {
+					log.Warn("Job", job, "cannot be scheduled yet:", message)
+					unschedulable = message
+				}
+				continue
+			}
+
+			status := containerStatus(pod, container)
+			if status == nil {
+				continue
+			}
+
+			if status.State.Running != nil || status.State.Terminated != nil {
+				return pod.Name, nil
+			}
+
+			if waiting := status.State.Waiting; waiting != nil && slices.Contains(failedReasons, waiting.Reason) {
+				return "", fmt.Errorf("Job %s failed to start: %s: %s", job, waiting.Reason, waiting.Message)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if unschedulable != "" {
+				return "", fmt.Errorf("Job %s could not be scheduled within %s: %s", job, startTimeout, unschedulable)
+			}
+			return "", fmt.Errorf("Job %s did not start within %s", job, startTimeout)
+		}
+
+		if err := sleep(ctx); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Returns the reason why a pod cannot be scheduled, if the scheduler reported
+// that it cannot.
+func schedulingFailure(pod core.Pod) (string, bool) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core.PodScheduled && condition.Status == core.ConditionFalse {
+			if condition.Message != "" {
+				return condition.Message, true
+			}
+			return condition.Reason, true
+		}
+	}
+	return "", false
+}
+
+// Waits until the container of the pod of the job has terminated, and returns
+// its exit code.
+func waitForExit(ctx context.Context, log *util.Logger, target *config.ResolvedTarget, job string, container string) (int, error) {
+	for {
+		pods, err := kubectl.GetPods(log, target, "job-name="+job)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, errInterrupted
+			}
+			return 0, err
+		}
+
+		for _, pod := range pods {
+			if status := containerStatus(pod, container); status != nil && status.State.Terminated != nil {
+				return int(status.State.Terminated.ExitCode), nil
+			}
+		}
+
+		if err := sleep(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func containerStatus(pod core.Pod, container string) *core.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == container {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return errInterrupted
+	case <-time.After(pollInterval):
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func deployment(name string, containers ...core.Container) apps.Deployment {
+	return apps.Deployment{
+		ObjectMeta: meta.ObjectMeta{Name: name},
+		Spec: apps.DeploymentSpec{
+			Selector: &meta.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"app": name, "team": "web"}},
+				Spec: core.PodSpec{
+					ServiceAccountName: "my-app",
+					RestartPolicy:      core.RestartPolicyAlways,
+					Containers:         containers,
+				},
+			},
+		},
+	}
+}
+
+func TestNewJob(t *testing.T) {
+	image := "eu.gcr.io/project/my-app@sha256:1"
+	deployments := []apps.Deployment{
+		deployment("proxy", core.Container{Name: "nginx", Image: "nginx"}),
+		deployment("web",
+			core.Container{Name: "cloudsql", Image: "cloud-sql-proxy"},
+			core.Container{
+				Name:           "app",
+				Image:          image,
+				Args:           []string{"server"},
+				Env:            []core.EnvVar{{Name: "RAILS_ENV", Value: "production"}},
+				ReadinessProbe: &core.Probe{},
+			},
+		),
+	}
+
+	job := newJob(deployments, image, []string{"bin/rake", "data:fix"}, time.Unix(1700000000, 0))
+
+	assert.Equal(t, "web-run-s44we8", job.Name)
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+
+	template := job.Spec.Template
+	assert.Equal(t, map[string]string{labelRun: "web"}, template.Labels)
+	assert.Equal(t, "my-app", template.Spec.ServiceAccountName)
+	assert.Equal(t, core.RestartPolicyNever, template.Spec.RestartPolicy)
+
+	assert.Len(t, template.Spec.Containers, 1)
+	container := template.Spec.Containers[0]
+	assert.Equal(t, "app", container.Name)
+	assert.Equal(t, image, container.Image)
+	assert.Equal(t, []string{"bin/rake", "data:fix"}, container.Command)
+	assert.Nil(t, container.Args)
+	assert.Nil(t, container.ReadinessProbe)
+	assert.Equal(t, "production", container.Env[0].Value)
+
+	// The deployment itself is left unchanged.
+	assert.Len(t, deployments[1].Spec.Template.Spec.Containers, 2)
+	assert.Equal(t, "web", deployments[1].Spec.Template.Labels["app"])
+}
+
+func TestNewJobWithoutDeployment(t *testing.T) {
+	deployments := []apps.Deployment{deployment("proxy", core.Container{Name: "nginx", Image: "nginx"})}
+	assert.Nil(t, newJob(deployments, "eu.gcr.io/project/my-app@sha256:1", []string{"true"}, time.Now()))
+}
+
+func TestSchedulingFailure(t *testing.T) {
+	pod := core.Pod{Status: core.PodStatus{Conditions: []core.PodCondition{{
+		Type:    core.PodScheduled,
+		Status:  core.ConditionFalse,
+		Reason:  "Unschedulable",
+		Message: "0/3 nodes are available: 3 Insufficient memory.",
+	}}}}
+
+	message, ok := schedulingFailure(pod)
+	assert.True(t, ok)
+	assert.Equal(t, "0/3 nodes are available: 3 Insufficient memory.", message)
+
+	pod.Status.Conditions[0].Status = core.ConditionTrue
+	_, ok = schedulingFailure(pod)
+	assert.False(t, ok)
+}
+
+func TestJobNameLength(t *testing.T) {
+	name := jobName(strings.Repeat("a", 70), time.Unix(1700000000, 0))
+	assert.Len(t, name, 63)
+	assert.True(t, strings.HasSuffix(name, "-run-s44we8"))
+}
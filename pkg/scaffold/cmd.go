@@ -60,9 +60,13 @@ type appEnv struct {
 type details struct {
 	ApiVersion uint
 	Customer   string
-	Project    string
+	Registry   string
+	Cache      string
 	Context    string
 	Apps       []app
+
+	// Host of the registry if it is accessed over plain HTTP.
+	InsecureRegistry string
 }
 
 func (d details) appNames() (names []string) {
@@ -73,10 +77,14 @@ func (d details) appNames() (names []string) {
 	return
 }
 
+// Option to select a registry that is not in a Google cloud project.
+const otherRegistry = "Other registry"
+
 func requestDetails(afs *afero.Afero, log *util.Logger, in io.Reader, out io.Writer) (*details, error) {
 	projects, err := findProjects()
 	if err != nil {
-		return nil, err
+		// Registries of other providers can be used without gcloud.
+		log.Debug(err)
 	}
 
 	contexts, err := findContexts()
@@ -101,32 +109,38 @@ func requestDetails(afs *afero.Afero, log *util.Logger, in io.Reader, out io.Wri
 
 	log.Note("Enter a few project details")
 
-	qs := []*survey.Question{
-		{
-			Name:      "customer",
-			Validate:  survey.Required,
-			Prompt:    &survey.Input{Message: "Namespace (e.g. customer name):"},
-			Transform: survey.TransformString(util.Slugify),
-		},
-		{
-			Name:     "project",
-			Validate: survey.Required,
-			Prompt: &survey.Select{
-				Message: "Select Google cloud project id:",
-				Options: projects,
-			},
-		},
-		{
-			Name:     "context",
-			Validate: survey.Required,
-			Prompt: &survey.Select{
-				Message: "Select Kubernetes cluster context:",
-				Options: contexts,
-			},
-		},
+	err = survey.AskOne(&survey.Input{Message: "Namespace (e.g. customer name):"}, &data.Customer,
+		survey.WithValidator(survey.Required))
+	if err != nil {
+		return nil, err
+	}
+	data.Customer = util.Slugify(data.Customer)
+
+	project := otherRegistry
+	if len(projects) > 0 {
+		err = survey.AskOne(&survey.Select{
+			Message: "Select Google cloud project id:",
+			Options: append(projects, otherRegistry),
+		}, &project)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if project == otherRegistry {
+		err = survey.AskOne(&survey.Input{Message: "Registry to push images to (e.g. ghcr.io/my-org or localhost:5000):"}, &data.Registry,
+			survey.WithValidator(survey.Required))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = survey.Ask(qs, data)
+	data.setRegistry(project)
+
+	err = survey.AskOne(&survey.Select{
+		Message: "Select Kubernetes cluster context:",
+		Options: contexts,
+	}, &data.Context, survey.WithValidator(survey.Required))
 	if err != nil {
 		return nil, err
 	}
@@ -134,6 +148,25 @@ func requestDetails(afs *afero.Afero, log *util.Logger, in io.Reader, out io.Wri
 	return data, nil
 }
 
+// Sets the registries for images and build cache. Images in a Google cloud
+// project are pushed to Container Registry and build cache to Artifact
+// Registry. Other registries are used as given, and registries on the local
+// machine are accessed over plain HTTP.
+func (d *details) setRegistry(project string) {
+	if project != otherRegistry {
+		d.Registry = "eu.gcr.io/" + project + "/" + d.Customer
+		d.Cache = "europe-west1-docker.pkg.dev/" + project + "/build-cache/" + d.Customer
+		return
+	}
+
+	d.Registry = strings.TrimSuffix(strings.TrimPrefix(d.Registry, "https://"), "/")
+	host, _, _ := strings.Cut(d.Registry, "/")
+	hostname, _, _ := strings.Cut(host, ":")
+	if hostname == "localhost" || strings.HasPrefix(hostname, "127.") || strings.HasSuffix(hostname, ".localhost") {
+		d.InsecureRegistry = host
+	}
+}
+
 func findProjects() ([]string, error) {
 	cmd := exec.Command("gcloud", "projects", "list", "--uri")
 	var out, errOut bytes.Buffer
@@ -143,8 +176,8 @@ func findProjects() ([]string, error) {
 		return nil, errors.Errorf("Failed to get projects: %s", errOut.String())
 	}
 
-	projects := strings.Split(out.String(), "\n")
-	for i, proj := range projects[:len(projects)-1] {
+	projects := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for i, proj := range projects {
 		projects[i] = filepath.Base(proj)
 	}
 
@@ -213,10 +246,19 @@ var kdeploy = template.Must(template.New(config.ConfigName).Parse(
 version: {{.ApiVersion}}
 
 # Private docker registry for deployable images
-registry: eu.gcr.io/{{.Project}}/{{.Customer}}
+registry: {{.Registry}}
+{{- if .Cache}}
 
 # Private docker registry for build cache images
-cache: europe-west1-docker.pkg.dev/{{.Project}}/build-cache/{{.Customer}}
+cache: {{.Cache}}
+{{- end}}
+{{- if .InsecureRegistry}}
+
+# Registries that are accessed over plain HTTP
+registries:
+- host: {{.InsecureRegistry}}
+  insecure: true
+{{- end}}
 
 # List of apps to build
 apps:
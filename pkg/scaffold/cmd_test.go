@@ -42,9 +42,9 @@ func TestWriteConfig(t *testing.T) {
 			Path: "apps/other-app",
 		}},
 		Customer: "a-customer-name",
-		Project:  "project-123456",
 		Context:  "cluster_Context",
 	}
+	details.setRegistry("project-123456")
 
 	err := writeConfig(fs, details)
 	assert.Nil(t, err)
@@ -55,9 +55,12 @@ func TestWriteConfig(t *testing.T) {
 		"# Check version compatibility with kd\n",
 		"version: 1\n",
 		"\n",
-		"# Private docker registry to push images to\n",
+		"# Private docker registry for deployable images\n",
 		"registry: eu.gcr.io/project-123456/a-customer-name\n",
 		"\n",
+		"# Private docker registry for build cache images\n",
+		"cache: europe-west1-docker.pkg.dev/project-123456/build-cache/a-customer-name\n",
+		"\n",
 		"# List of apps to build\n",
 		"apps:\n",
 		"- name: my-website\n",
@@ -103,3 +106,30 @@ func TestWriteConfig(t *testing.T) {
 		"- ../_base\n",
 	}, ""), string(accManifest))
 }
+
+func TestWriteConfigLocalRegistry(t *testing.T) {
+	details := &details{
+		ApiVersion: 1,
+		Customer:   "a-customer-name",
+		Registry:   "localhost:5000/a-customer-name",
+		Context:    "kind-kind",
+	}
+	details.setRegistry(otherRegistry)
+
+	err := writeConfig(fs, details)
+	assert.Nil(t, err)
+
+	kdeploy, err := fs.ReadFile("kdeploy.conf")
+	assert.Nil(t, err)
+	assert.Contains(t, string(kdeploy), strings.Join([]string{
+		"# Private docker registry for deployable images\n",
+		"registry: localhost:5000/a-customer-name\n",
+		"\n",
+		"# Registries that are accessed over plain HTTP\n",
+		"registries:\n",
+		"- host: localhost:5000\n",
+		"  insecure: true\n",
+		"\n",
+		"# List of apps to build\n",
+	}, ""))
+}
@@ -0,0 +1,398 @@
+package status
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/voormedia/kd/pkg/config"
+	"github.com/voormedia/kd/pkg/internal/docker"
+	"github.com/voormedia/kd/pkg/internal/kubectl"
+	"github.com/voormedia/kd/pkg/internal/kustomize"
+	"github.com/voormedia/kd/pkg/util"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+)
+
+// States of the rollout of an app, from best to worst.
+const (
+	RolloutComplete    = "complete"
+	RolloutProgressing = "progressing"
+	RolloutMissing     = "not deployed"
+	RolloutFailed      = "failed"
+	RolloutUnknown     = "unknown"
+)
+
+var rolloutOrder = []string{"", RolloutComplete, RolloutProgressing, RolloutMissing, RolloutFailed, RolloutUnknown}
+
+// Workloads that are rolled out, as opposed to jobs.
+var rolloutKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// The status of an app in a target. Replicas and restarts are the totals of
+// all workloads of the app. Current is nil if it could not be determined.
+type Status struct {
+	App      string     `json:"app"`
+	Target   string     `json:"target"`
+	Digest   string     `json:"digest,omitempty"`
+	Tag      string     `json:"tag,omitempty"`
+	Current  *bool      `json:"current,omitempty"`
+	Rollout  string     `json:"rollout"`
+	Ready    int32      `json:"ready"`
+	Desired  int32      `json:"desired"`
+	Restarts int32      `json:"restarts"`
+	Started  *time.Time `json:"started,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Looks up images of an app in the registry.
+type registry interface {
+	// Returns the digest of the image with the tag, or an empty string if
+	// there is no such tag.
+	digest(tag string) (string, error)
+
+	// Returns the git revision that the image with the digest was built from,
+	// or an empty string if it is unknown.
+	revision(digest string) (string, error)
+}
+
+type remote struct {
+	log *util.Logger
+	app *config.ResolvedApp
+}
+
+func (r remote) digest(tag string) (string, error) {
+	img, err := docker.GetImage(r.log, r.app.RepositoryWithTag(tag))
+	if docker.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return img.Descriptor.Digest.String(), nil
+}
+
+func (r remote) revision(digest string) (string, error) {
+	img, err := docker.GetImage(r.log, r.app.RepositoryWithDigest(digest))
+	if docker.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	details, err := docker.DescribeImage(r.log, img)
+	if err != nil {
+		return "", err
+	}
+	return details.Revision, nil
+}
+
+// Returns the status of each app in each target. The image that is running is
+// compared with the image tagged with the name of the target in the registry,
+// which is the image that was last deployed with kd. Errors are reported in
+// the status, so that the status of other apps and targets is still shown.
+func Get(log *util.Logger, resolvedApps []*config.ResolvedApp, targets []*config.ResolvedTarget) []Status {
+	var result []Status
+	for _, app := range resolvedApps {
+		for _, target := range targets {
+			log.Note("Retrieving status of", app.Name, "in", target.Name)
+			status := Status{App: app.Name, Target: target.Name}
+
+			tag, err := status.retrieve(log, app, target)
+			if err != nil {
+				status.Rollout = RolloutUnknown
+				status.Error = err.Error()
+			} else if !app.SkipBuild {
+				if err := status.identify(remote{log, app}, tag, target.Name); err != nil {
+					status.Error = err.Error()
+				}
+			} else {
+				status.Tag = tag
+			}
+
+			result = append(result, status)
+		}
+	}
+
+	return result
+}
+
+// Retrieves the rollout state, replicas, restarts and image digest of the
+// workloads of the app. The digest is the digest of the image that the most
+// recently started pod runs. Returns the tag of the image, if it was deployed
+// by tag instead of by digest.
+func (status *Status) retrieve(log *util.Logger, app *config.ResolvedApp, target *config.ResolvedTarget) (string, error) {
+	workloads, err := kustomize.GetWorkloads(log, app, target)
+	if err != nil {
+		return "", err
+	}
+
+	repository := app.TargetRepository(target)
+	tag, specDigest := "", ""
+	var newest time.Time
+	for _, workload := range workloads {
+		if !slices.Contains(rolloutKinds, workload.Kind) {
+			continue
+		}
+
+		bytes, err := kubectl.GetResource(log, target, strings.ToLower(workload.Kind), workload.Name)
+		if err != nil {
+			return "", err
+		}
+
+		if bytes == nil {
+			status.Rollout = worst(status.Rollout, RolloutMissing)
+			continue
+		}
+
+		state, err := parseWorkload(workload.Kind, bytes)
+		if err != nil {
+			return "", err
+		}
+
+		status.Rollout = worst(status.Rollout, state.rollout)
+		status.Ready += state.ready
+		status.Desired += state.desired
+
+		for _, image := range state.images {
+			if t, dgst, ok := parseImage(repository, image); ok && specDigest == "" && tag == "" {
+				tag, specDigest = t, dgst
+			}
+		}
+
+		pods, err := kubectl.GetPods(log, target, workload.LabelSelector())
+		if err != nil {
+			return "", err
+		}
+
+		for _, pod := range pods {
+			for _, container := range pod.Status.ContainerStatuses {
+				status.Restarts += container.RestartCount
+			}
+
+			start := pod.Status.StartTime
+			if start == nil {
+				continue
+			}
+
+			if status.Started == nil || start.Time.Before(*status.Started) {
+				status.Started = &start.Time
+			}
+
+			if dgst := podDigest(repository, pod); dgst != "" && start.Time.After(newest) {
+				status.Digest, newest = dgst, start.Time
+			}
+		}
+	}
+
+	if status.Digest == "" {
+		status.Digest = specDigest
+	}
+
+	if status.Rollout == "" {
+		status.Rollout = RolloutMissing
+	}
+
+	return tag, nil
+}
+
+// Returns the digest of the image of the app that runs in a pod, as reported
+// by the container runtime.
+func podDigest(repository string, pod core.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		if _, _, ok := parseImage(repository, container.Image); !ok {
+			continue
+		}
+
+		for _, s := range pod.Status.ContainerStatuses {
+			if s.Name != container.Name {
+				continue
+			}
+
+			if _, dgst, ok := strings.Cut(s.ImageID, "@"); ok {
+				return dgst
+			}
+		}
+	}
+	return ""
+}
+
+// Sets the digest and tag of the running image, and whether it is the image
+// that is tagged with the name of the target. Images without a tag in their
+// reference are described by the tag of the git revision they were built
+// from, or otherwise by the name of the target.
+func (status *Status) identify(reg registry, tag string, target string) error {
+	status.Tag = tag
+
+	current, err := reg.digest(target)
+	if err != nil {
+		return err
+	}
+
+	if status.Digest == "" && tag != "" {
+		if tag == target {
+			status.Digest = current
+		} else if status.Digest, err = reg.digest(tag); err != nil {
+			return err
+		}
+	}
+
+	if status.Digest == "" {
+		return nil
+	}
+
+	isCurrent := status.Digest == current
+	status.Current = &isCurrent
+
+	if status.Tag != "" {
+		return nil
+	}
+
+	revision, err := reg.revision(status.Digest)
+	if err != nil {
+		return err
+	}
+
+	if revision != "" {
+		dgst, err := reg.digest(revision)
+		if err != nil {
+			return err
+		}
+
+		if dgst == status.Digest {
+			status.Tag = revision
+			return nil
+		}
+	}
+
+	if isCurrent {
+		status.Tag = target
+	}
+	return nil
+}
+
+// Returns the tag and digest of an image reference if it refers to the given
+// repository.
+func parseImage(repository string, image string) (tag string, digest string, ok bool) {
+	rest, ok := strings.CutPrefix(image, repository)
+	if !ok {
+		return "", "", false
+	}
+
+	switch {
+	case rest == "":
+		return config.DefaultTag, "", true
+	case strings.HasPrefix(rest, "@"):
+		return "", rest[1:], true
+	case strings.HasPrefix(rest, ":"):
+		tag, digest, _ := strings.Cut(rest[1:], "@")
+		return tag, digest, true
+	default:
+		return "", "", false
+	}
+}
+
+type workloadState struct {
+	rollout string
+	ready   int32
+	desired int32
+	images  []string
+}
+
+// Determines the rollout state of a workload in the same way as 'kubectl
+// rollout status'.
+func parseWorkload(kind string, bytes []byte) (workloadState, error) {
+	var state workloadState
+	var template core.PodTemplateSpec
+
+	switch kind {
+	case "Deployment":
+		var d apps.Deployment
+		if err := json.Unmarshal(bytes, &d); err != nil {
+			return state, err
+		}
+
+		template = d.Spec.Template
+		state.desired = replicas(d.Spec.Replicas)
+		state.ready = d.Status.ReadyReplicas
+
+		switch {
+		case deadlineExceeded(d.Status.Conditions):
+			state.rollout = RolloutFailed
+		case d.Status.ObservedGeneration < d.Generation,
+			d.Status.UpdatedReplicas < state.desired,
+			d.Status.Replicas > d.Status.UpdatedReplicas,
+			d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+			state.rollout = RolloutProgressing
+		default:
+			state.rollout = RolloutComplete
+		}
+
+	case "StatefulSet":
+		var s apps.StatefulSet
+		if err := json.Unmarshal(bytes, &s); err != nil {
+			return state, err
+		}
+
+		template = s.Spec.Template
+		state.desired = replicas(s.Spec.Replicas)
+		state.ready = s.Status.ReadyReplicas
+
+		switch {
+		case s.Status.ObservedGeneration < s.Generation,
+			s.Status.ReadyReplicas < state.desired,
+			s.Status.UpdatedReplicas < state.desired,
+			s.Status.UpdateRevision != s.Status.CurrentRevision:
+			state.rollout = RolloutProgressing
+		default:
+			state.rollout = RolloutComplete
+		}
+
+	case "DaemonSet":
+		var d apps.DaemonSet
+		if err := json.Unmarshal(bytes, &d); err != nil {
+			return state, err
+		}
+
+		template = d.Spec.Template
+		state.desired = d.Status.DesiredNumberScheduled
+		state.ready = d.Status.NumberReady
+
+		switch {
+		case d.Status.ObservedGeneration < d.Generation,
+			d.Status.UpdatedNumberScheduled < state.desired,
+			d.Status.NumberAvailable < state.desired:
+			state.rollout = RolloutProgressing
+		default:
+			state.rollout = RolloutComplete
+		}
+	}
+
+	for _, container := range template.Spec.Containers {
+		state.images = append(state.images, container.Image)
+	}
+
+	return state, nil
+}
+
+func replicas(n *int32) int32 {
+	if n == nil {
+		return 1
+	}
+	return *n
+}
+
+func deadlineExceeded(conditions []apps.DeploymentCondition) bool {
+	for _, condition := range conditions {
+		if condition.Type == apps.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+func worst(a string, b string) string {
+	if slices.Index(rolloutOrder, b) > slices.Index(rolloutOrder, a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,142 @@
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func TestParseDeployment(t *testing.T) {
+	state, err := parseWorkload("Deployment", []byte(`{
+		"metadata": {"generation": 3},
+		"spec": {
+			"replicas": 3,
+			"template": {"spec": {"containers": [{"name": "app", "image": "eu.gcr.io/project/web@sha256:1"}]}}
+		},
+		"status": {"observedGeneration": 3, "replicas": 4, "updatedReplicas": 3, "readyReplicas": 3, "availableReplicas": 3}
+	}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, RolloutProgressing, state.rollout)
+	assert.Equal(t, int32(3), state.ready)
+	assert.Equal(t, int32(3), state.desired)
+	assert.Equal(t, []string{"eu.gcr.io/project/web@sha256:1"}, state.images)
+}
+
+func TestParseDeploymentComplete(t *testing.T) {
+	state, err := parseWorkload("Deployment", []byte(`{
+		"metadata": {"generation": 3},
+		"status": {"observedGeneration": 3, "replicas": 1, "updatedReplicas": 1, "readyReplicas": 1, "availableReplicas": 1}
+	}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, RolloutComplete, state.rollout)
+	assert.Equal(t, int32(1), state.desired)
+}
+
+func TestParseDeploymentFailed(t *testing.T) {
+	state, err := parseWorkload("Deployment", []byte(`{
+		"status": {"conditions": [{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}]}
+	}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, RolloutFailed, state.rollout)
+}
+
+func TestParseImage(t *testing.T) {
+	tag, digest, ok := parseImage("eu.gcr.io/project/web", "eu.gcr.io/project/web@sha256:1")
+	assert.Equal(t, []interface{}{"", "sha256:1", true}, []interface{}{tag, digest, ok})
+
+	tag, digest, ok = parseImage("eu.gcr.io/project/web", "eu.gcr.io/project/web:abc1234")
+	assert.Equal(t, []interface{}{"abc1234", "", true}, []interface{}{tag, digest, ok})
+
+	_, _, ok = parseImage("eu.gcr.io/project/web", "eu.gcr.io/project/web-worker@sha256:1")
+	assert.False(t, ok)
+}
+
+type fakeRegistry struct {
+	tags      map[string]string
+	revisions map[string]string
+}
+
+func (r fakeRegistry) digest(tag string) (string, error) {
+	return r.tags[tag], nil
+}
+
+func (r fakeRegistry) revision(digest string) (string, error) {
+	return r.revisions[digest], nil
+}
+
+type failingRegistry struct{}
+
+func (failingRegistry) digest(string) (string, error) {
+	return "", errors.New("unauthorized")
+}
+
+func (failingRegistry) revision(string) (string, error) {
+	return "", errors.New("unauthorized")
+}
+
+func TestIdentify(t *testing.T) {
+	reg := fakeRegistry{
+		tags: map[string]string{
+			"latest":     "sha256:2",
+			"def5678":    "sha256:2",
+			"production": "sha256:2",
+			"acceptance": "sha256:3",
+		},
+		revisions: map[string]string{
+			"sha256:2": "def5678",
+			"sha256:3": "abc1234",
+		},
+	}
+
+	current := Status{Digest: "sha256:2"}
+	assert.Nil(t, current.identify(reg, "", "production"))
+	assert.True(t, *current.Current)
+	assert.Equal(t, "def5678", current.Tag)
+
+	differs := Status{Digest: "sha256:2"}
+	assert.Nil(t, differs.identify(reg, "", "acceptance"))
+	assert.False(t, *differs.Current)
+
+	byTag := Status{}
+	assert.Nil(t, byTag.identify(reg, "acceptance", "acceptance"))
+	assert.True(t, *byTag.Current)
+	assert.Equal(t, "sha256:3", byTag.Digest)
+	assert.Equal(t, "acceptance", byTag.Tag)
+
+	untagged := Status{Digest: "sha256:3"}
+	assert.Nil(t, untagged.identify(reg, "", "acceptance"))
+	assert.Equal(t, "acceptance", untagged.Tag)
+}
+
+func TestIdentifyUnknown(t *testing.T) {
+	status := Status{Digest: "sha256:2"}
+	assert.EqualError(t, status.identify(failingRegistry{}, "", "production"), "unauthorized")
+	assert.Nil(t, status.Current)
+}
+
+func TestPodDigest(t *testing.T) {
+	pod := core.Pod{
+		Spec: core.PodSpec{Containers: []core.Container{
+			{Name: "proxy", Image: "gcr.io/cloudsql-docker/gce-proxy:1.33"},
+			{Name: "app", Image: "eu.gcr.io/project/web:abc1234"},
+		}},
+		Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{
+			{Name: "proxy", ImageID: "gcr.io/cloudsql-docker/gce-proxy@sha256:1"},
+			{Name: "app", ImageID: "docker-pullable://eu.gcr.io/project/web@sha256:2"},
+		}},
+	}
+
+	assert.Equal(t, "sha256:2", podDigest("eu.gcr.io/project/web", pod))
+	assert.Equal(t, "", podDigest("eu.gcr.io/project/worker", pod))
+}
+
+func TestWorst(t *testing.T) {
+	assert.Equal(t, RolloutComplete, worst("", RolloutComplete))
+	assert.Equal(t, RolloutProgressing, worst(RolloutProgressing, RolloutComplete))
+	assert.Equal(t, RolloutFailed, worst(RolloutMissing, RolloutFailed))
+}
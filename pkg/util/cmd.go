@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -20,6 +21,20 @@ func Run(log *Logger, name string, args ...string) error {
 	return cmd.Run()
 }
 
+// Runs a command with additional environment variables, which are not set in
+// the environment of kd itself.
+func RunWithEnv(log *Logger, env []string, name string, args ...string) error {
+	log.Debug("Executing:", name, strings.Join(args, " "))
+
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader([]byte{})
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+
+	return cmd.Run()
+}
+
 func RunWithoutStdErr(log *Logger, name string, args ...string) error {
 	log.Debug("Executing:", name, strings.Join(args, " "))
 
@@ -59,6 +74,23 @@ func RunWithInput(log *Logger, input []byte, name string, args ...string) error
 	return cmd.Run()
 }
 
+func CaptureWithInput(log *Logger, input []byte, name string, args ...string) ([]byte, error) {
+	log.Debug("Executing with input and capturing output:", name, strings.Join(args, " "))
+
+	cmd := exec.Command(name, args...)
+	buf := &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = buf
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
 func Capture(log *Logger, name string, args ...string) ([]byte, error) {
 	log.Debug("Executing and capturing output:", name, strings.Join(args, " "))
 
@@ -75,3 +107,24 @@ func Capture(log *Logger, name string, args ...string) ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+func CaptureWithoutStdErr(log *Logger, name string, args ...string) ([]byte, error) {
+	log.Debug("Executing and capturing output:", name, strings.Join(args, " "))
+
+	cmd := exec.Command(name, args...)
+	buf := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader([]byte{})
+	cmd.Stderr = errBuf
+	cmd.Stdout = buf
+
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(errBuf.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
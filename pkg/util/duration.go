@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parses a duration such as "30d" or "12h". In addition to the units
+// supported by time.ParseDuration, days are supported with "d".
+func ParseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age '%s'", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age '%s'", value)
+	}
+	return duration, nil
+}
+
+// Formats a duration in the largest whole unit, e.g. "3d" or "5h".
+func FormatAge(duration time.Duration) string {
+	switch {
+	case duration >= 24*time.Hour:
+		return strconv.Itoa(int(duration/(24*time.Hour))) + "d"
+	case duration >= time.Hour:
+		return strconv.Itoa(int(duration/time.Hour)) + "h"
+	case duration >= time.Minute:
+		return strconv.Itoa(int(duration/time.Minute)) + "m"
+	default:
+		return strconv.Itoa(int(duration/time.Second)) + "s"
+	}
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAge(t *testing.T) {
+	age, err := ParseAge("30d")
+	assert.Nil(t, err)
+	assert.Equal(t, 30*24*time.Hour, age)
+
+	age, err = ParseAge("36h")
+	assert.Nil(t, err)
+	assert.Equal(t, 36*time.Hour, age)
+
+	_, err = ParseAge("a week")
+	assert.Equal(t, "invalid age 'a week'", err.Error())
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "30d", FormatAge(30*24*time.Hour))
+	assert.Equal(t, "1d", FormatAge(36*time.Hour))
+	assert.Equal(t, "5h", FormatAge(5*time.Hour+10*time.Minute))
+	assert.Equal(t, "42s", FormatAge(42*time.Second))
+}
@@ -1,17 +1,41 @@
 package util
 
 import (
+	"bytes"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+// Finds the git directory for the given path. In worktrees and submodules
+// .git is a file that refers to the actual git directory.
 func findGitDir(dir string) (string, error) {
 	for {
-		gitPath := filepath.Join(dir, ".git", "HEAD")
-		if _, err := os.Stat(gitPath); err == nil {
-			return gitPath, nil
+		gitPath := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			if info.IsDir() {
+				return gitPath, nil
+			}
+
+			content, err := os.ReadFile(gitPath)
+			if err != nil {
+				return "", fmt.Errorf("error reading %s: %w", gitPath, err)
+			}
+
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+			if !ok {
+				return "", fmt.Errorf("error parsing %s", gitPath)
+			}
+
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+
+			return gitDir, nil
 		}
 
 		parentDir := filepath.Dir(dir)
@@ -25,25 +49,271 @@ func findGitDir(dir string) (string, error) {
 	return "", fmt.Errorf("failed to find git directory")
 }
 
+// Returns the full name of the current branch, e.g. "feature/login". If HEAD
+// is detached, as is common in CI checkouts, the branch is taken from the
+// environment variables of well-known CI systems instead.
 func GetCurrentBranch(log *Logger, path string) (string, error) {
-	log.Debug("Reading current git branch from .git/HEAD")
+	log.Debug("Reading current git branch from HEAD")
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
 
-	gitHeadPath, err := findGitDir(path)
+	gitDir, err := findGitDir(absPath)
 	if err != nil {
 		return "", err
 	}
 
-	headContent, err := os.ReadFile(gitHeadPath)
+	headContent, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
 	if err != nil {
-		return "", fmt.Errorf("error reading .git/HEAD: %w", err)
+		return "", fmt.Errorf("error reading HEAD: %w", err)
 	}
 
 	// Check if HEAD points to a branch reference
-	if strings.HasPrefix(string(headContent), "ref: ") {
-		branchPath := strings.TrimSpace(strings.TrimPrefix(string(headContent), "ref: "))
-		branchName := filepath.Base(branchPath)
-		return branchName, nil
+	if ref, ok := strings.CutPrefix(strings.TrimSpace(string(headContent)), "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/"), nil
+	}
+
+	if branch := branchFromCIEnvironment(); branch != "" {
+		log.Debug("HEAD is detached, using branch from CI environment")
+		return branch, nil
+	}
+
+	return "", fmt.Errorf("HEAD is detached and no CI branch is known")
+}
+
+func branchFromCIEnvironment() string {
+	// GitHub Actions
+	if branch := os.Getenv("GITHUB_HEAD_REF"); branch != "" {
+		return branch
 	}
+	if ref := os.Getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/heads/") {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+
+	// GitLab CI
+	if branch := os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"); branch != "" {
+		return branch
+	}
+	if branch := os.Getenv("CI_COMMIT_BRANCH"); branch != "" {
+		return branch
+	}
+
+	// Bitbucket Pipelines
+	if branch := os.Getenv("BITBUCKET_BRANCH"); branch != "" {
+		return branch
+	}
+
+	// Jenkins
+	if branch := os.Getenv("CHANGE_BRANCH"); branch != "" {
+		return branch
+	}
+	if branch := os.Getenv("BRANCH_NAME"); branch != "" {
+		return branch
+	}
+	if branch := os.Getenv("GIT_BRANCH"); branch != "" {
+		return strings.TrimPrefix(branch, "origin/")
+	}
+
+	return ""
+}
+
+func GetCurrentCommit(log *Logger, path string) (string, error) {
+	log.Debug("Reading current git commit")
+
+	output, err := git(log, path, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func GetDescription(log *Logger, path string) (string, error) {
+	output, err := git(log, path, "describe", "--tags", "--always")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func GetRemoteURL(log *Logger, path string) (string, error) {
+	output, err := git(log, path, "remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+
+	return NormalizeRemoteURL(strings.TrimSpace(string(output))), nil
+}
+
+func IsWorkingTreeDirty(log *Logger, path string) (bool, error) {
+	output, err := git(log, path, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// Returns the names of all local and remote branches.
+func ListBranches(log *Logger, path string) ([]string, error) {
+	output, err := git(log, path, "for-each-ref", "--format=%(refname)", "refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	seen := map[string]bool{"HEAD": true}
+	for _, ref := range strings.Fields(string(output)) {
+		name := branchName(ref)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			branches = append(branches, name)
+		}
+	}
+
+	return branches, nil
+}
+
+// Returns the branch name of a local or remote branch reference.
+func branchName(ref string) string {
+	if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		return name
+	}
+
+	// Strip remote name from refs/remotes/<remote>/<branch>.
+	parts := strings.SplitN(ref, "/", 4)
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
+
+// Number of recently updated branches, besides the preferred branches, that
+// are considered as the branch that the current branch was forked from.
+const forkCandidates = 10
+
+// Returns the branch that the current branch was most likely forked from: the
+// branch with the fewest commits between its merge base with HEAD and HEAD.
+// Ties are resolved in favour of the preferred branches, then recency. Only
+// the preferred branches and the most recently updated other branches are
+// considered. Branches that contain HEAD, such as the current branch or
+// branches forked from it, are skipped.
+func GetForkBranch(log *Logger, path string, current string, preferred []string) (string, error) {
+	output, err := git(log, path, "for-each-ref",
+		"--sort=-committerdate", "--no-contains=HEAD", "--format=%(refname)",
+		"refs/heads", "refs/remotes")
+	if err != nil {
+		return "", err
+	}
+
+	seen := map[string]bool{current: true, "HEAD": true}
+	others := 0
+	best, bestDistance := "", -1
+
+	for _, ref := range strings.Fields(string(output)) {
+		name := branchName(ref)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		isPreferred := slices.Contains(preferred, name)
+		if !isPreferred {
+			if others == forkCandidates {
+				continue
+			}
+			others++
+		}
+
+		count, err := git(log, path, "rev-list", "--count", ref+"..HEAD")
+		if err != nil {
+			continue
+		}
+
+		distance, err := strconv.Atoi(strings.TrimSpace(string(count)))
+		if err != nil {
+			continue
+		}
+
+		if bestDistance < 0 || distance < bestDistance || (distance == bestDistance && isPreferred && !slices.Contains(preferred, best)) {
+			best, bestDistance = name, distance
+		}
+	}
+
+	return best, nil
+}
+
+// Reports whether there are uncommitted changes or untracked files in the
+// given directory.
+func IsContextDirty(log *Logger, path string) (bool, error) {
+	output, err := git(log, path, "status", "--porcelain", "--untracked-files=normal", "--", ".")
+	if err != nil {
+		return false, err
+	}
+
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// Returns the mode and object id of all tracked files in the given directory,
+// by path relative to that directory.
+func ListTrackedFiles(log *Logger, path string) (map[string]string, error) {
+	output, err := git(log, path, "ls-files", "--stage", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	for _, entry := range strings.Split(string(output), "\x00") {
+		// Entries look like: <mode> <object> <stage>\t<path>
+		info, file, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(info)
+		if len(fields) < 2 {
+			continue
+		}
+
+		files[file] = fields[0] + " " + fields[1]
+	}
+
+	return files, nil
+}
+
+// Converts a git remote to a browsable HTTPS URL without credentials, e.g.
+// git@github.com:voormedia/kd.git becomes https://github.com/voormedia/kd.
+func NormalizeRemoteURL(remote string) string {
+	remote = strings.TrimSuffix(remote, ".git")
+
+	if !strings.Contains(remote, "://") {
+		// SCP-like syntax: [user@]host:path
+		if host, path, ok := strings.Cut(remote, ":"); ok {
+			if i := strings.LastIndex(host, "@"); i >= 0 {
+				host = host[i+1:]
+			}
+			return "https://" + host + "/" + strings.TrimPrefix(path, "/")
+		}
+		return remote
+	}
+
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return ""
+	}
+
+	parsed.User = nil
+	if parsed.Scheme == "ssh" || parsed.Scheme == "git" || parsed.Scheme == "http" {
+		parsed.Scheme = "https"
+		parsed.Host = parsed.Hostname()
+	}
+
+	return parsed.String()
+}
 
-	return "", fmt.Errorf("error parsing git ref")
+func git(log *Logger, path string, args ...string) ([]byte, error) {
+	return CaptureWithoutStdErr(log, "git", append([]string{"-C", path}, args...)...)
 }